@@ -0,0 +1,220 @@
+package budget
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+)
+
+// ExceededError is returned when a request would exceed a budget
+type ExceededError struct {
+	Limit  string
+	Reason string
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s budget exceeded: %s", e.Limit, e.Reason)
+}
+
+// EstimatedCompletionTokens is what a commit message is assumed to cost
+// in completion tokens before the request is sent
+const EstimatedCompletionTokens = 300
+
+// lockTimeout bounds how long Record waits for another process, and
+// how old a lock may get before it is considered abandoned
+const lockTimeout = 5 * time.Second
+
+// Request describes the request about to be sent
+type Request struct {
+	Provider string
+	Model    string
+	Prompt   string
+	// Cost is the estimated cost in USD. Free requests, to local models,
+	// are never held back by the daily spend.
+	Cost float64
+	Free bool
+	// Unpriced requests go to a model without a known price. Their cost
+	// can't be counted, so they are refused while a daily spend is set.
+	Unpriced bool
+}
+
+// state is the accounting persisted between runs
+type state struct {
+	Day    string   `json:"day"`
+	Cost   float64  `json:"cost_usd"`
+	Tokens int      `json:"tokens"`
+	Recent []sample `json:"recent"`
+}
+
+// sample is a request sent in the last minute
+type sample struct {
+	Time     time.Time `json:"time"`
+	Provider string    `json:"provider"`
+}
+
+// StatePath returns the path of the budget state file
+func StatePath() string {
+	dir := config.GetGlobalConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "budget.json")
+}
+
+// EstimateTokens gives a rough token count for a prompt
+func EstimateTokens(prompt string) int {
+	return len(prompt) / 4
+}
+
+// Check returns an *ExceededError if req would exceed any configured limit
+func Check(cfg *config.Config, req Request) error {
+	b := cfg.Budget
+
+	if b.MaxTokensPerRequest > 0 {
+		if n := EstimateTokens(req.Prompt); n > b.MaxTokensPerRequest {
+			return &ExceededError{
+				Limit:  "per-request token",
+				Reason: fmt.Sprintf("prompt is ~%d tokens, max is %d", n, b.MaxTokensPerRequest),
+			}
+		}
+	}
+
+	return CheckSpend(cfg, req)
+}
+
+// CheckSpend is Check without the per-request token limit, for a fallback
+// that is meant to take prompts too large for the primary provider. The
+// rate limit counts requests to the same provider, whatever the model.
+func CheckSpend(cfg *config.Config, req Request) error {
+	b := cfg.Budget
+	if b.MaxCostPerDay <= 0 && b.MaxRequestsPerMinute <= 0 {
+		return nil
+	}
+
+	if b.MaxCostPerDay > 0 && req.Unpriced {
+		return &ExceededError{
+			Limit:  "daily spend",
+			Reason: fmt.Sprintf("price of %s/%s is unknown, so its cost can't be counted. Add it to usage.pricing", req.Provider, req.Model),
+		}
+	}
+
+	st := load(time.Now())
+
+	if b.MaxCostPerDay > 0 && !req.Free && (st.Cost >= b.MaxCostPerDay || st.Cost+req.Cost > b.MaxCostPerDay) {
+		return &ExceededError{
+			Limit:  "daily spend",
+			Reason: fmt.Sprintf("$%.4f spent today and ~$%.4f for this request, max is $%.2f", st.Cost, req.Cost, b.MaxCostPerDay),
+		}
+	}
+
+	if b.MaxRequestsPerMinute > 0 {
+		n := 0
+		for _, r := range st.Recent {
+			if r.Provider == req.Provider {
+				n++
+			}
+		}
+		if n >= b.MaxRequestsPerMinute {
+			return &ExceededError{
+				Limit:  "rate",
+				Reason: fmt.Sprintf("%d requests to %s in the last minute, max is %d", n, req.Provider, b.MaxRequestsPerMinute),
+			}
+		}
+	}
+
+	return nil
+}
+
+// Record adds a completed request to providerName to the accounting.
+// Hooks of concurrent commits may record at once, so the state file is
+// locked while it is updated.
+func Record(providerName string, tokens int, cost float64) error {
+	path := StatePath()
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	unlock, err := lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := time.Now()
+	st := load(now)
+	st.Cost += cost
+	st.Tokens += tokens
+	st.Recent = append(st.Recent, sample{Time: now.UTC(), Provider: providerName})
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// lock creates path exclusively, waiting for another holder to finish,
+// and returns the function that releases it. A lock older than
+// lockTimeout was left by a process that died and is taken over.
+func lock(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock budget: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockTimeout {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock budget: %s is held by another process", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// load reads the state, resetting daily totals and dropping
+// requests older than a minute
+func load(now time.Time) state {
+	day := now.Format("2006-01-02")
+	st := state{Day: day}
+
+	if path := StatePath(); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			_ = json.Unmarshal(data, &st)
+		}
+	}
+
+	if st.Day != day {
+		st.Day = day
+		st.Cost = 0
+		st.Tokens = 0
+	}
+
+	recent := st.Recent[:0]
+	for _, r := range st.Recent {
+		if now.Sub(r.Time) < time.Minute {
+			recent = append(recent, r)
+		}
+	}
+	st.Recent = recent
+
+	return st
+}
@@ -0,0 +1,107 @@
+package budget
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+)
+
+func setup(t *testing.T, b config.BudgetConfig) *config.Config {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	cfg := config.Default()
+	cfg.Budget = b
+	return cfg
+}
+
+func exceeded(err error, limit string) bool {
+	var e *ExceededError
+	return errors.As(err, &e) && e.Limit == limit
+}
+
+func TestDailySpendIncludesRequest(t *testing.T) {
+	cfg := setup(t, config.BudgetConfig{MaxCostPerDay: 1})
+	if err := Record("openai", 1000, 0.9); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Check(cfg, Request{Provider: "openai", Cost: 0.05}); err != nil {
+		t.Errorf("request within budget: %v", err)
+	}
+	if err := Check(cfg, Request{Provider: "openai", Cost: 0.2}); !exceeded(err, "daily spend") {
+		t.Errorf("request over budget: %v", err)
+	}
+	if err := Check(cfg, Request{Provider: "ollama", Free: true}); err != nil {
+		t.Errorf("free request: %v", err)
+	}
+
+	Record("openai", 1000, 0.2)
+	if err := Check(cfg, Request{Provider: "openai"}); !exceeded(err, "daily spend") {
+		t.Errorf("unpriced request after the budget is spent: %v", err)
+	}
+}
+
+func TestDailySpendRefusesUnpricedModel(t *testing.T) {
+	req := Request{Provider: "openai-compatible", Model: "my-model", Unpriced: true}
+
+	cfg := setup(t, config.BudgetConfig{MaxCostPerDay: 1})
+	err := Check(cfg, req)
+	if !exceeded(err, "daily spend") || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("unpriced request under a cost cap: %v", err)
+	}
+
+	cfg.Budget = config.BudgetConfig{MaxRequestsPerMinute: 5}
+	if err := Check(cfg, req); err != nil {
+		t.Errorf("unpriced request without a cost cap: %v", err)
+	}
+}
+
+func TestRateIsPerProvider(t *testing.T) {
+	cfg := setup(t, config.BudgetConfig{MaxRequestsPerMinute: 2})
+	Record("openai", 10, 0)
+	Record("openai", 10, 0)
+
+	if err := CheckSpend(cfg, Request{Provider: "openai"}); !exceeded(err, "rate") {
+		t.Errorf("third request to openai: %v", err)
+	}
+	if err := CheckSpend(cfg, Request{Provider: "ollama"}); err != nil {
+		t.Errorf("request to another provider: %v", err)
+	}
+}
+
+func TestTokenLimit(t *testing.T) {
+	cfg := setup(t, config.BudgetConfig{MaxTokensPerRequest: 10})
+	req := Request{Provider: "openai", Prompt: string(make([]byte, 100))}
+	if err := Check(cfg, req); !exceeded(err, "per-request token") {
+		t.Errorf("large prompt: %v", err)
+	}
+	if err := CheckSpend(cfg, req); err != nil {
+		t.Errorf("CheckSpend applied the token limit: %v", err)
+	}
+}
+
+func TestRecordWithoutLimitsAndConcurrently(t *testing.T) {
+	setup(t, config.BudgetConfig{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Record("openai", 5, 0.01); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	st := load(time.Now())
+	if st.Tokens != 100 || len(st.Recent) != 20 {
+		t.Errorf("recorded %d tokens and %d requests, want 100 and 20", st.Tokens, len(st.Recent))
+	}
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/provider"
+)
+
+// Entry is a cached provider response
+type Entry struct {
+	Created  time.Time      `json:"created"`
+	Provider string         `json:"provider"`
+	Model    string         `json:"model"`
+	Text     string         `json:"text"`
+	Usage    provider.Usage `json:"usage"`
+}
+
+// Stats describes the cache contents
+type Stats struct {
+	Dir     string
+	Entries int
+	Expired int
+	Bytes   int64
+}
+
+// Dir returns the cache directory inside the global config directory
+func Dir() string {
+	dir := config.GetGlobalConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "cache")
+}
+
+// Key hashes everything that affects the response
+func Key(providerName, model string, params []string, prompt string) string {
+	h := sha256.New()
+	for _, part := range append([]string{providerName, model}, params...) {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte(prompt))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns a cached entry if it exists and has not expired
+func Get(cfg *config.Config, key string) (*Entry, bool) {
+	dir := Dir()
+	if dir == "" {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if cfg.Cache.TTL > 0 && time.Since(entry.Created) > cfg.Cache.TTL {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Put stores an entry and evicts old entries beyond the size limit
+func Put(cfg *config.Config, key string, entry Entry) error {
+	dir := Dir()
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, key+".json"), data, 0644); err != nil {
+		return err
+	}
+
+	return prune(cfg, dir)
+}
+
+// Clear removes all cached entries and returns how many were removed
+func Clear() (int, error) {
+	dir := Dir()
+	if dir == "" {
+		return 0, nil
+	}
+
+	files, err := entries(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range files {
+		if err := os.Remove(f.path); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+// GetStats returns the number and size of cached entries
+func GetStats(cfg *config.Config) (Stats, error) {
+	dir := Dir()
+	stats := Stats{Dir: dir}
+	if dir == "" {
+		return stats, nil
+	}
+
+	files, err := entries(dir)
+	if err != nil {
+		return stats, err
+	}
+	for _, f := range files {
+		stats.Entries++
+		stats.Bytes += f.size
+		if cfg.Cache.TTL > 0 && time.Since(f.modTime) > cfg.Cache.TTL {
+			stats.Expired++
+		}
+	}
+	return stats, nil
+}
+
+type file struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func entries(dir string) ([]file, error) {
+	des, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []file
+	for _, de := range des {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), ".json") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{
+			path:    filepath.Join(dir, de.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+// prune drops expired entries, then the oldest ones until the cache fits
+func prune(cfg *config.Config, dir string) error {
+	files, err := entries(dir)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	limit := int64(cfg.Cache.MaxSizeMB) << 20
+	var total int64
+	for _, f := range files {
+		if cfg.Cache.TTL > 0 && time.Since(f.modTime) > cfg.Cache.TTL {
+			os.Remove(f.path)
+			continue
+		}
+		total += f.size
+		if limit > 0 && total > limit {
+			os.Remove(f.path)
+		}
+	}
+	return nil
+}
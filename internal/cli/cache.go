@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/josinSbazin/AutoCommit/internal/cache"
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the response cache",
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached responses",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := cache.Clear()
+		if err != nil {
+			return fmt.Errorf("failed to clear cache: %w", err)
+		}
+		fmt.Printf("Removed %d cached response(s)\n", n)
+		return nil
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show cache statistics",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		stats, err := cache.GetStats(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to read cache: %w", err)
+		}
+
+		fmt.Printf("Directory: %s\n", stats.Dir)
+		fmt.Printf("Enabled:   %t\n", cfg.Cache.Enabled)
+		fmt.Printf("Entries:   %d (%d expired)\n", stats.Entries, stats.Expired)
+		fmt.Printf("Size:      %.1f KB of %d MB\n", float64(stats.Bytes)/1024, cfg.Cache.MaxSizeMB)
+		fmt.Printf("TTL:       %s\n", cfg.Cache.TTL)
+		return nil
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+}
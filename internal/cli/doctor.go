@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/josinSbazin/AutoCommit/internal/config"
 	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/hook"
 	"github.com/josinSbazin/AutoCommit/internal/provider"
+	"github.com/josinSbazin/AutoCommit/internal/server"
 )
 
 var doctorCmd = &cobra.Command{
@@ -78,16 +82,39 @@ func runDoctor(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if plugins := provider.Plugins(); len(plugins) > 0 {
+		fmt.Printf("Provider plugins... %s\n", strings.Join(plugins, ", "))
+	}
+
+	if cfg != nil {
+		fmt.Print("Daemon... ")
+		if client := server.Connect(cfg); client != nil {
+			fmt.Printf("Running on %s\n", client.Address())
+		} else {
+			fmt.Println("Not running (optional, start with 'autocommit serve')")
+		}
+	}
+
 	fmt.Print("Git hook... ")
-	hookPath := ".git/hooks/prepare-commit-msg"
-	if _, err := os.Stat(hookPath); os.IsNotExist(err) {
-		fmt.Println("Not installed (optional)")
+	if dir, err := git.GetHookDir(); err != nil {
+		fmt.Println("Skipped (not in a git repository)")
 	} else {
-		content, _ := os.ReadFile(hookPath)
-		if isOurHook(string(content)) {
-			fmt.Println("Installed")
-		} else {
-			fmt.Println("Different hook installed")
+		switch hook.Status(dir.Path) {
+		case hook.NotInstalled:
+			fmt.Println("Not installed (optional)")
+		case hook.Foreign:
+			fmt.Printf("Different hook installed in %s (%s)\n", dir.Path, dir.Source())
+		default:
+			fmt.Printf("Installed in %s (%s)\n", dir.Path, dir.Source())
+		}
+	}
+
+	if root, err := git.GetRootDir(); err == nil {
+		for _, m := range hook.DetectManagers(root) {
+			fmt.Printf("Hook manager %s... %s\n", m.Name, configuredState(m.Configured))
+			if !m.Configured {
+				fmt.Printf("   Run 'autocommit hook install --manager %s'\n", m.Name)
+			}
 		}
 	}
 
@@ -106,6 +133,11 @@ func hasAPIKey(providerName string) bool {
 }
 
 func getAPIKeyEnvName(providerName string) string {
+	// Plugins handle their own authentication
+	if strings.HasPrefix(providerName, provider.ExecPrefix) || slices.Contains(provider.Plugins(), providerName) {
+		return ""
+	}
+
 	switch providerName {
 	case "anthropic":
 		return "ANTHROPIC_API_KEY"
@@ -115,7 +147,7 @@ func getAPIKeyEnvName(providerName string) string {
 		return "GIGACHAT_CLIENT_ID"
 	case "yandexgpt":
 		return "YANDEX_API_KEY"
-	case "ollama":
+	case "ollama", "heuristic":
 		return ""
 	case "openai-compatible":
 		return "AUTOCOMMIT_API_KEY"
@@ -2,15 +2,27 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/josinSbazin/AutoCommit/internal/budget"
+	"github.com/josinSbazin/AutoCommit/internal/cache"
 	"github.com/josinSbazin/AutoCommit/internal/config"
 	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/heuristic"
+	"github.com/josinSbazin/AutoCommit/internal/message"
 	"github.com/josinSbazin/AutoCommit/internal/prompt"
 	"github.com/josinSbazin/AutoCommit/internal/provider"
+	"github.com/josinSbazin/AutoCommit/internal/server"
 	"github.com/josinSbazin/AutoCommit/internal/ui"
+	"github.com/josinSbazin/AutoCommit/internal/usage"
 )
 
 var generateCmd = &cobra.Command{
@@ -23,12 +35,36 @@ func init() {
 	generateCmd.Flags().BoolP("dry-run", "d", false, "Don't commit, just show message")
 	generateCmd.Flags().StringP("output", "o", "", "Write message to file")
 	generateCmd.Flags().Bool("hook-mode", false, "Run in git hook mode")
+	generateCmd.Flags().StringP("format", "f", "text", "Output format: text or json")
+	generateCmd.Flags().Bool("no-cache", false, "Skip the response cache")
 }
 
-func runGenerate(cmd *cobra.Command, args []string) error {
+func runGenerate(cmd *cobra.Command, args []string) (err error) {
 	ctx := context.Background()
 
-	cfg, err := config.Load()
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	outputFile, _ := cmd.Flags().GetString("output")
+	hookMode, _ := cmd.Flags().GetBool("hook-mode")
+
+	// A failing prepare-commit-msg hook aborts the commit, so in hook mode
+	// any error turns into an offline draft for the editor instead
+	var cfg *config.Config
+	var diff *git.DiffResult
+	if hookMode && outputFile != "" {
+		defer func() {
+			if err != nil {
+				err = writeHookFallback(outputFile, cfg, diff, err)
+			}
+		}()
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("unknown format: %s (use text or json)", format)
+	}
+	jsonOutput := format == "json"
+
+	cfg, err = config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -40,11 +76,18 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		cfg.Model = m
 	}
 
-	if !git.IsRepo() {
-		return fmt.Errorf("not a git repository")
+	// A repository can opt out of global or shared hooks
+	if hookMode && !cfg.Enabled {
+		return nil
+	}
+
+	repo, err := git.Open(".", cfg.GitBackend)
+	if err != nil {
+		return err
 	}
+	git.SetDefault(repo)
 
-	diff, err := git.GetStagedDiff()
+	diff, err = repo.StagedDiff()
 	if err != nil {
 		return fmt.Errorf("failed to get diff: %w", err)
 	}
@@ -53,35 +96,71 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no staged changes. Use 'git add' first")
 	}
 
-	history, _ := git.GetCommitHistory(cfg.Context.HistoryCount)
-	branch, _ := git.GetCurrentBranch()
+	history, _ := repo.History(cfg.Context.HistoryCount)
+	branch, _ := repo.Branch()
 
 	promptBuilder := prompt.NewBuilder(cfg)
-	promptText := promptBuilder.Build(diff, history, branch)
+	conversation, err := promptBuilder.Build(diff, history, branch)
+	if err != nil {
+		return err
+	}
+	if err := promptBuilder.Fallback(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s, using the built-in prompt\n", err)
+	}
+
+	// git commit must never hang on a slow provider
+	if hookMode && cfg.Timeouts.Hook > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeouts.Hook)
+		defer cancel()
+	}
+
+	ctx = provider.WithDiff(ctx, diff)
+
+	if hookMode && cfg.Behavior.Prefill && outputFile != "" {
+		gen, err := prefilled(ctx, cfg, conversation)
+		if err != nil {
+			return fmt.Errorf("failed to generate message: %w", err)
+		}
+		if gen != nil {
+			return os.WriteFile(outputFile, []byte(gen.Text), 0644)
+		}
+	}
 
-	prov, err := provider.Get(cfg)
+	generate, err := newGenerator(cfg, ".", conversation)
 	if err != nil {
-		return fmt.Errorf("failed to get provider: %w", err)
+		return err
 	}
 
-	spinner := ui.NewSpinner("Generating commit message...")
-	spinner.Start()
+	var spinner *ui.Spinner
+	if !jsonOutput && !hookMode {
+		spinner = ui.NewSpinner("Generating commit message...")
+		spinner.Start()
+	}
 
-	message, err := prov.Generate(ctx, promptText)
-	spinner.Stop()
+	noCache, _ := cmd.Flags().GetBool("no-cache")
+	gen, err := generate(ctx, !noCache)
+	if spinner != nil {
+		spinner.Stop()
+	}
 
 	if err != nil {
 		return fmt.Errorf("failed to generate message: %w", err)
 	}
+	message := gen.Text
 
-	dryRun, _ := cmd.Flags().GetBool("dry-run")
-	outputFile, _ := cmd.Flags().GetString("output")
-	hookMode, _ := cmd.Flags().GetBool("hook-mode")
+	if verbose, _ := cmd.Flags().GetBool("verbose"); verbose && !jsonOutput {
+		printGenerationInfo(gen)
+	}
 
 	if hookMode && outputFile != "" {
 		return os.WriteFile(outputFile, []byte(message), 0644)
 	}
 
+	if jsonOutput {
+		return writeJSONResult(outputFile, newGenerateResult(cfg, diff, gen))
+	}
+
 	if outputFile != "" {
 		if err := os.WriteFile(outputFile, []byte(message), 0644); err != nil {
 			return fmt.Errorf("failed to write file: %w", err)
@@ -95,21 +174,38 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	return runInteractive(ctx, cfg, prov, promptText, message)
+	yes, _ := cmd.Flags().GetBool("yes")
+	noInteractive, _ := cmd.Flags().GetBool("no-interactive")
+	interactive := cfg.Behavior.Interactive && !noInteractive && ui.IsInteractive()
+
+	if yes || (interactive && !cfg.Behavior.ConfirmBeforeCommit) {
+		return commitMessage(message)
+	}
+
+	if !interactive {
+		fmt.Println(message)
+		return fmt.Errorf("refusing to commit without confirmation in non-interactive mode. Use --yes to commit")
+	}
+
+	return runInteractive(ctx, generate, message)
 }
 
-func runInteractive(ctx context.Context, cfg *config.Config, prov provider.Provider, promptText, message string) error {
+func commitMessage(message string) error {
+	if err := git.CreateCommit(message); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	ui.PrintSuccess("Committed!")
+	return nil
+}
+
+func runInteractive(ctx context.Context, generate generator, message string) error {
 	for {
 		ui.PrintCommitMessage(message)
 		action := ui.AskAction()
 
 		switch action {
 		case ui.ActionAccept:
-			if err := git.CreateCommit(message); err != nil {
-				return fmt.Errorf("failed to commit: %w", err)
-			}
-			ui.PrintSuccess("Committed!")
-			return nil
+			return commitMessage(message)
 
 		case ui.ActionEdit:
 			edited, err := ui.EditInEditor(message)
@@ -121,13 +217,17 @@ func runInteractive(ctx context.Context, cfg *config.Config, prov provider.Provi
 		case ui.ActionRegenerate:
 			spinner := ui.NewSpinner("Regenerating...")
 			spinner.Start()
-			newMessage, err := prov.Generate(ctx, promptText)
+			gen, err := generate(ctx, false)
 			spinner.Stop()
+			if errors.Is(err, errInterrupted) {
+				fmt.Println("Aborted.")
+				return nil
+			}
 			if err != nil {
 				ui.PrintError("Failed to regenerate: " + err.Error())
 				continue
 			}
-			message = newMessage
+			message = gen.Text
 
 		case ui.ActionQuit:
 			fmt.Println("Aborted.")
@@ -135,3 +235,349 @@ func runInteractive(ctx context.Context, cfg *config.Config, prov provider.Provi
 		}
 	}
 }
+
+var errInterrupted = errors.New("interrupted")
+
+// generator produces a message for one prompt. With useCache false the
+// cache lookup is skipped.
+type generator func(ctx context.Context, useCache bool) (*generation, error)
+
+// newGenerator sends requests for the repository in dir to a running
+// daemon, which keeps providers between calls, and otherwise uses a
+// provider created here
+func newGenerator(cfg *config.Config, dir string, conversation prompt.Prompt) (generator, error) {
+	var send func(ctx context.Context, conversation prompt.Prompt, useCache bool) (*generation, error)
+	if client := server.Connect(cfg); client != nil {
+		send = func(ctx context.Context, conversation prompt.Prompt, useCache bool) (*generation, error) {
+			return daemonGenerate(ctx, client, cfg, dir, conversation, useCache)
+		}
+	} else {
+		prov, err := provider.Get(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get provider: %w", err)
+		}
+		send = func(ctx context.Context, conversation prompt.Prompt, useCache bool) (*generation, error) {
+			return generateMessage(ctx, cfg, prov, conversation, useCache)
+		}
+	}
+
+	return func(ctx context.Context, useCache bool) (*generation, error) {
+		gen, err := send(ctx, conversation, useCache)
+		if err != nil {
+			return nil, err
+		}
+		return repair(ctx, cfg, gen, send, useCache)
+	}, nil
+}
+
+// repair asks once more when a reply breaks the configured rules, passing
+// the reply and the errors back to the model. The original reply is kept
+// if the retry fails or is no better.
+func repair(ctx context.Context, cfg *config.Config, gen *generation, send func(context.Context, prompt.Prompt, bool) (*generation, error), useCache bool) (*generation, error) {
+	issues := message.Validate(cfg, gen.Text)
+	// The heuristic provider ignores the prompt, so it would answer the same
+	if !message.HasErrors(issues) || gen.Provider == "heuristic" {
+		return gen, nil
+	}
+
+	repaired, err := send(ctx, gen.Prompt.FollowUp(gen.Text, repairInstruction(issues)), useCache)
+	if errors.Is(err, errInterrupted) {
+		return nil, err
+	}
+	if err != nil || message.HasErrors(message.Validate(cfg, repaired.Text)) {
+		return gen, nil
+	}
+	return repaired, nil
+}
+
+// repairInstruction lists the errors in a reply for the model to fix
+func repairInstruction(issues []message.Issue) string {
+	var sb strings.Builder
+	sb.WriteString("This commit message breaks the following rules:\n")
+	for _, issue := range issues {
+		if issue.Severity == message.SeverityError {
+			sb.WriteString("- " + issue.Message + "\n")
+		}
+	}
+	sb.WriteString("\nReturn ONLY the corrected commit message, nothing else.")
+	return sb.String()
+}
+
+// generateInDir generates a message for the staged changes in dir, for
+// servers that aren't run from the repository
+func generateInDir(ctx context.Context, dir string, regenerate bool) (string, error) {
+	cfg, err := config.LoadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+
+	repo, err := git.Open(dir, cfg.GitBackend)
+	if err != nil {
+		return "", err
+	}
+	diff, err := repo.StagedDiff()
+	if err != nil {
+		return "", fmt.Errorf("failed to get diff: %w", err)
+	}
+	if diff.IsEmpty() {
+		return "", fmt.Errorf("no staged changes. Use 'git add' first")
+	}
+
+	history, _ := repo.History(cfg.Context.HistoryCount)
+	branch, _ := repo.Branch()
+	conversation, err := prompt.NewBuilder(cfg).Build(diff, history, branch)
+	if err != nil {
+		return "", err
+	}
+
+	generate, err := newGenerator(cfg, dir, conversation)
+	if err != nil {
+		return "", err
+	}
+	gen, err := generate(provider.WithDiff(ctx, diff), !regenerate)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(gen.Text), nil
+}
+
+// generation is a generated message with its accounting metadata
+type generation struct {
+	*provider.Result
+	Provider string
+	Model    string
+	Latency  time.Duration
+	Cost     float64
+	Unpriced bool
+	Cached   bool
+	// Prompt is the conversation that produced the message, continued by
+	// repair
+	Prompt prompt.Prompt
+}
+
+// generateMessage returns a cached response if there is one, otherwise it
+// enforces budgets, calls the provider and records token usage.
+// With useCache false the lookup is skipped but the result is still stored.
+func generateMessage(ctx context.Context, cfg *config.Config, prov provider.Provider, conversation prompt.Prompt, useCache bool) (*generation, error) {
+	if gen, ok := cachedGeneration(cfg, prov, conversation, useCache); ok {
+		return gen, nil
+	}
+
+	primary := prov
+	prov, err := applyBudget(cfg, prov, conversation)
+	if err != nil {
+		return nil, err
+	}
+	// A fallback answers under its own key, and may have answered before
+	if prov != primary {
+		if gen, ok := cachedGeneration(cfg, prov, conversation, useCache); ok {
+			return gen, nil
+		}
+	}
+	key := cacheKey(cfg, prov, conversation)
+
+	// Ctrl-C cancels the in-flight request instead of killing the process,
+	// so callers can stop the spinner and exit cleanly
+	genCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	started := time.Now()
+	result, err := provider.Send(genCtx, prov, conversation)
+	if err != nil {
+		switch {
+		case errors.Is(genCtx.Err(), context.DeadlineExceeded):
+			return nil, fmt.Errorf("timed out after %s", time.Since(started).Round(time.Second))
+		case genCtx.Err() != nil:
+			return nil, errInterrupted
+		}
+		return nil, err
+	}
+	stop()
+
+	_, priced := usage.LookupPrice(cfg, prov.Name(), prov.Model())
+	gen := &generation{
+		Result:   result,
+		Provider: prov.Name(),
+		Model:    prov.Model(),
+		Latency:  time.Since(started),
+		Cost:     usage.Cost(cfg, prov.Name(), prov.Model(), result.Usage),
+		Unpriced: !priced,
+		Prompt:   conversation,
+	}
+
+	// Spend counts even while no limit is set, so one set later applies
+	// to the whole day
+	_ = budget.Record(prov.Name(), result.Usage.Total(), gen.Cost)
+
+	if cfg.Cache.Enabled {
+		_ = cache.Put(cfg, key, cache.Entry{
+			Created:  started.UTC(),
+			Provider: gen.Provider,
+			Model:    gen.Model,
+			Text:     result.Text,
+			Usage:    result.Usage,
+		})
+	}
+
+	if cfg.Usage.Log {
+		repo, _ := git.GetRootDir()
+		if diff := provider.DiffFromContext(ctx); diff != nil && diff.Repo() != nil {
+			repo, _ = diff.Repo().Root()
+		}
+		_ = usage.Append(usage.Record{
+			Time:             started.UTC(),
+			Provider:         prov.Name(),
+			Model:            prov.Model(),
+			Repo:             repo,
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+			Cost:             gen.Cost,
+			Unpriced:         gen.Unpriced,
+		})
+	}
+
+	return gen, nil
+}
+
+// cachedGeneration looks up the answer prov gave to conversation
+func cachedGeneration(cfg *config.Config, prov provider.Provider, conversation prompt.Prompt, useCache bool) (*generation, bool) {
+	if !cfg.Cache.Enabled || !useCache {
+		return nil, false
+	}
+	entry, ok := cache.Get(cfg, cacheKey(cfg, prov, conversation))
+	if !ok {
+		return nil, false
+	}
+	return &generation{
+		Result:   &provider.Result{Text: entry.Text, Usage: entry.Usage},
+		Provider: entry.Provider,
+		Model:    entry.Model,
+		Cached:   true,
+		Prompt:   conversation,
+	}, true
+}
+
+// cacheKey identifies a request to prov by everything that shapes the
+// answer: the provider and model, the settings in requestParams and the
+// whole conversation, roles included
+func cacheKey(cfg *config.Config, prov provider.Provider, conversation prompt.Prompt) string {
+	return cache.Key(prov.Name(), prov.Model(), requestParams(cfg), conversationKey(conversation))
+}
+
+// requestParams lists the settings besides provider and model that
+// change what a provider is asked
+func requestParams(cfg *config.Config) []string {
+	params := []string{cfg.Endpoint, cfg.FolderID}
+	if len(cfg.PluginParams) > 0 {
+		// Map keys are sorted, so equal params give equal text
+		data, _ := json.Marshal(cfg.PluginParams)
+		params = append(params, string(data))
+	}
+	return params
+}
+
+// conversationKey is the conversation with its roles, so a system prompt
+// and the same text sent as a user message differ
+func conversationKey(conversation prompt.Prompt) string {
+	data, err := json.Marshal(conversation)
+	if err != nil {
+		return conversation.Text()
+	}
+	return string(data)
+}
+
+// applyBudget returns the provider to use for a request, degrading to the
+// configured fallback when a budget would be exceeded
+func applyBudget(cfg *config.Config, prov provider.Provider, conversation prompt.Prompt) (provider.Provider, error) {
+	err := budget.Check(cfg, budgetRequest(cfg, prov, conversation))
+	if err == nil {
+		return prov, nil
+	}
+
+	b := cfg.Budget
+	if b.OnExceed != "fallback" || (b.FallbackProvider == "" && b.FallbackModel == "") {
+		return nil, fmt.Errorf("%w. Adjust the budget section in your config", err)
+	}
+
+	fallbackCfg := *cfg
+	if b.FallbackProvider != "" {
+		fallbackCfg.Provider = b.FallbackProvider
+	}
+	fallbackCfg.Model = b.FallbackModel
+
+	fallback, ferr := provider.Get(&fallbackCfg)
+	if ferr != nil {
+		return nil, fmt.Errorf("%v; fallback provider unavailable: %w", err, ferr)
+	}
+	// The fallback may take larger prompts, but it spends from the same
+	// day and, for the same provider, the same rate
+	if ferr := budget.CheckSpend(cfg, budgetRequest(cfg, fallback, conversation)); ferr != nil {
+		return nil, fmt.Errorf("%v; fallback %s/%s: %w. Adjust the budget section in your config", err, fallback.Name(), fallback.Model(), ferr)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s, falling back to %s/%s\n", err, fallback.Name(), fallback.Model())
+	return fallback, nil
+}
+
+// budgetRequest estimates what sending conversation to prov will cost
+func budgetRequest(cfg *config.Config, prov provider.Provider, conversation prompt.Prompt) budget.Request {
+	text := conversation.Text()
+	price, known := usage.LookupPrice(cfg, prov.Name(), prov.Model())
+	return budget.Request{
+		Provider: prov.Name(),
+		Model:    prov.Model(),
+		Prompt:   text,
+		Cost: usage.Cost(cfg, prov.Name(), prov.Model(), provider.Usage{
+			PromptTokens:     budget.EstimateTokens(text),
+			CompletionTokens: budget.EstimatedCompletionTokens,
+		}),
+		Free:     known && price.Prompt == 0 && price.Completion == 0,
+		Unpriced: !known,
+	}
+}
+
+func printGenerationInfo(gen *generation) {
+	if gen.Cached {
+		fmt.Fprintf(os.Stderr, "%s/%s: cached response\n", gen.Provider, gen.Model)
+		return
+	}
+	cost := fmt.Sprintf("~$%.4f", gen.Cost)
+	if gen.Unpriced {
+		cost = "cost unknown"
+	}
+	fmt.Fprintf(os.Stderr, "%s/%s: %d tokens (%d prompt, %d completion), %s, %s\n",
+		gen.Provider, gen.Model,
+		gen.Usage.Total(), gen.Usage.PromptTokens, gen.Usage.CompletionTokens,
+		cost, gen.Latency.Round(time.Millisecond))
+}
+
+// writeHookFallback writes a heuristic draft with the failure reason as a
+// comment, so git still opens the editor with something useful
+func writeHookFallback(path string, cfg *config.Config, diff *git.DiffResult, reason error) error {
+	fmt.Fprintf(os.Stderr, "autocommit: %s\n", reason)
+
+	if diff == nil || diff.IsEmpty() {
+		return nil
+	}
+	if cfg == nil {
+		cfg = config.Default()
+	}
+
+	draft := heuristic.Generate(cfg, diff)
+	// Lines git doesn't see as comments would end up in the commit
+	comment := git.CommentChar(".")
+	if comment == "" {
+		comment = git.UnusedCommentChar(draft)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(draft)
+	sb.WriteString("\n\n")
+	sb.WriteString(comment + " autocommit: " + strings.ReplaceAll(reason.Error(), "\n", " ") + "\n")
+	sb.WriteString(comment + " This draft was generated offline from the staged files. Edit as needed.\n")
+
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
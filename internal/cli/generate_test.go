@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
+	"github.com/josinSbazin/AutoCommit/internal/provider"
+)
+
+func TestRepair(t *testing.T) {
+	cfg := config.Default()
+	cfg.Style = "conventional"
+
+	var sent []prompt.Prompt
+	send := func(replies ...string) func(context.Context, prompt.Prompt, bool) (*generation, error) {
+		return func(ctx context.Context, conversation prompt.Prompt, useCache bool) (*generation, error) {
+			sent = append(sent, conversation)
+			text := replies[0]
+			replies = replies[1:]
+			return &generation{Result: &provider.Result{Text: text}, Provider: "openai", Prompt: conversation}, nil
+		}
+	}
+	first := func(text string) *generation {
+		return &generation{Result: &provider.Result{Text: text}, Provider: "openai", Prompt: prompt.User("describe")}
+	}
+
+	sent = nil
+	gen, err := repair(context.Background(), cfg, first("Added the parser"), send("feat: add the parser"), true)
+	if err != nil || gen.Text != "feat: add the parser" {
+		t.Fatalf("repair = %v, %v", gen, err)
+	}
+	msgs := sent[0].Messages
+	if len(msgs) != 3 || msgs[1].Content != "Added the parser" || !strings.Contains(msgs[2].Content, "type(scope): description") {
+		t.Errorf("follow-up = %+v", msgs)
+	}
+
+	// A retry that is still invalid keeps the first reply
+	sent = nil
+	if gen, _ := repair(context.Background(), cfg, first("Added the parser"), send("Adds the parser"), true); gen.Text != "Added the parser" {
+		t.Errorf("kept %q", gen.Text)
+	}
+
+	// Valid replies are not retried
+	sent = nil
+	repair(context.Background(), cfg, first("feat: add the parser"), send(), true)
+	if len(sent) != 0 {
+		t.Errorf("valid reply was retried")
+	}
+}
+
+func TestHookFallbackCommentChar(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	diff := &git.DiffResult{Files: []git.FileChange{{Path: "parser.go", Status: "modified", Additions: 1}}}
+	path := filepath.Join(dir, "COMMIT_EDITMSG")
+	// With auto, a character the draft doesn't use
+	for setting, commentChar := range map[string]string{";": ";", "#": "#", "auto": "#"} {
+		exec.Command("git", "config", "core.commentChar", setting).Run()
+		if err := writeHookFallback(path, config.Default(), diff, errors.New("timed out")); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		for _, line := range lines[len(lines)-2:] {
+			if !strings.HasPrefix(line, commentChar+" ") {
+				t.Errorf("core.commentChar %s: line %q is not a comment", setting, line)
+			}
+		}
+	}
+}
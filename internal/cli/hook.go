@@ -3,9 +3,13 @@ package cli
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/hook"
 )
 
 var hookCmd = &cobra.Command{
@@ -17,10 +21,43 @@ var hookInstallCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Install git hook",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := installHook(); err != nil {
+		if manager, _ := cmd.Flags().GetString("manager"); manager != "" {
+			return installManager(manager)
+		}
+		if global, _ := cmd.Flags().GetBool("global"); global {
+			template, _ := cmd.Flags().GetBool("template")
+			return installGlobal(template)
+		}
+
+		mode := hook.ModeBlock
+		if chain, _ := cmd.Flags().GetBool("chain"); chain {
+			mode = hook.ModeChain
+		}
+
+		dir, err := git.GetHookDir()
+		if err != nil {
+			return err
+		}
+
+		hookPath, err := hook.Install(dir.Path, mode)
+		if err != nil {
 			return err
 		}
-		fmt.Println("Git hook installed")
+		fmt.Printf("Git hook installed at %s\n", hookPath)
+		if _, err := os.Stat(hook.BackupPath(dir.Path)); err == nil {
+			fmt.Printf("Previous hook saved to %s\n", hook.BackupPath(dir.Path))
+		}
+
+		if prefill, _ := cmd.Flags().GetBool("prefill"); prefill {
+			prefillPath, err := hook.InstallPrefill(dir.Path)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Prefill hook installed at %s\n", prefillPath)
+			if cfg, err := config.Load(); err == nil && !cfg.Behavior.Prefill {
+				fmt.Println("Set 'behavior.prefill: true' in your config to enable it")
+			}
+		}
 		return nil
 	},
 }
@@ -29,27 +66,46 @@ var hookUninstallCmd = &cobra.Command{
 	Use:   "uninstall",
 	Short: "Uninstall git hook",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		hookPath := ".git/hooks/prepare-commit-msg"
-
-		if _, err := os.Stat(hookPath); os.IsNotExist(err) {
-			fmt.Println("No hook installed")
+		if manager, _ := cmd.Flags().GetString("manager"); manager != "" {
+			root, err := git.GetRootDir()
+			if err != nil {
+				return err
+			}
+			if err := hook.UninstallManager(root, manager); err != nil {
+				return err
+			}
+			fmt.Printf("Removed autocommit from %s config\n", manager)
 			return nil
 		}
+		if global, _ := cmd.Flags().GetBool("global"); global {
+			return uninstallGlobal()
+		}
 
-		content, err := os.ReadFile(hookPath)
+		restore, _ := cmd.Flags().GetBool("restore")
+
+		dir, err := git.GetHookDir()
 		if err != nil {
 			return err
 		}
 
-		if !isOurHook(string(content)) {
-			return fmt.Errorf("hook exists but wasn't installed by autocommit")
+		if err := hook.UninstallPrefill(dir.Path); err != nil {
+			return err
+		}
+
+		if !restore && hook.Status(dir.Path) == hook.NotInstalled {
+			fmt.Printf("No hook installed in %s\n", dir.Path)
+			return nil
 		}
 
-		if err := os.Remove(hookPath); err != nil {
+		if err := hook.Uninstall(dir.Path, restore); err != nil {
 			return err
 		}
 
-		fmt.Println("Git hook uninstalled")
+		if restore {
+			fmt.Printf("Previous hook restored in %s\n", dir.Path)
+		} else {
+			fmt.Printf("Git hook uninstalled from %s\n", dir.Path)
+		}
 		return nil
 	},
 }
@@ -58,34 +114,241 @@ var hookStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Check hook status",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		hookPath := ".git/hooks/prepare-commit-msg"
-
-		if _, err := os.Stat(hookPath); os.IsNotExist(err) {
-			fmt.Println("Hook not installed")
+		if global, _ := cmd.Flags().GetBool("global"); global {
+			printGlobalStatus()
 			return nil
 		}
 
-		content, err := os.ReadFile(hookPath)
+		dir, err := git.GetHookDir()
 		if err != nil {
 			return err
 		}
 
-		if isOurHook(string(content)) {
+		fmt.Printf("Hooks directory: %s (%s)\n", dir.Path, dir.Source())
+
+		switch hook.Status(dir.Path) {
+		case hook.NotInstalled:
+			fmt.Println("Hook not installed")
+		case hook.Installed:
 			fmt.Println("AutoCommit hook is installed")
-		} else {
+		case hook.Chained:
+			fmt.Printf("AutoCommit hook is installed via %s.d\n", hook.Name)
+		default:
 			fmt.Println("Hook exists but is not managed by AutoCommit")
 		}
 
+		if _, err := os.Stat(hook.BackupPath(dir.Path)); err == nil {
+			fmt.Printf("Backup of the previous hook: %s\n", hook.BackupPath(dir.Path))
+		}
+		if hook.HasPrefill(dir.Path) {
+			fmt.Printf("Prefill hook is installed in %s\n", hook.PrefillName)
+		}
+
+		if root, err := git.GetRootDir(); err == nil {
+			for _, m := range hook.DetectManagers(root) {
+				fmt.Printf("Hook manager %s (%s): %s\n", m.Name, m.Config, configuredState(m.Configured))
+			}
+		}
+
 		return nil
 	},
 }
 
+var hookRunCmd = &cobra.Command{
+	Use:   "run <message-file> [source] [sha]",
+	Short: "Run the prepare-commit-msg hook (for hook managers)",
+	Args:  cobra.RangeArgs(1, 3),
+	RunE:  runHook,
+}
+
 func init() {
+	hookInstallCmd.Flags().Bool("chain", false, "Run hooks from prepare-commit-msg.d, keeping the existing hook as one of them")
+	hookInstallCmd.Flags().String("manager", "", "Configure a hook manager instead: pre-commit, husky or lefthook")
+	hookInstallCmd.Flags().Bool("global", false, "Install for all repositories via a global core.hooksPath")
+	hookInstallCmd.Flags().Bool("prefill", false, "Also generate messages in the background when the index changes")
+	hookInstallCmd.Flags().Bool("template", false, "With --global, use init.templateDir so only new clones get the hook")
+	hookUninstallCmd.Flags().Bool("restore", false, "Restore the hook saved at install time")
+	hookUninstallCmd.Flags().String("manager", "", "Remove autocommit from a hook manager's config")
+	hookUninstallCmd.Flags().Bool("global", false, "Remove the global hooks")
+	hookStatusCmd.Flags().Bool("global", false, "Show the global hook state")
+
 	hookCmd.AddCommand(hookInstallCmd)
 	hookCmd.AddCommand(hookUninstallCmd)
 	hookCmd.AddCommand(hookStatusCmd)
+	hookCmd.AddCommand(hookRunCmd)
+}
+
+func installManager(manager string) error {
+	root, err := git.GetRootDir()
+	if err != nil {
+		return err
+	}
+
+	// Pin pre-commit to this release; development builds track main
+	rev := "main"
+	if strings.HasPrefix(versionStr, "v") && !strings.Contains(versionStr, "-") {
+		rev = versionStr
+	}
+
+	path, err := hook.InstallManager(root, manager, rev)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Configured %s in %s\n", manager, path)
+
+	switch manager {
+	case hook.ManagerPreCommit:
+		fmt.Println("Run 'pre-commit install' to activate it")
+	case hook.ManagerLefthook:
+		fmt.Println("Run 'lefthook install' to activate it")
+	}
+	return nil
+}
+
+// globalHookDirs returns the managed core.hooksPath and init.templateDir
+func globalHookDirs() (hooksDir, templateDir string, err error) {
+	base := config.GetGlobalConfigDir()
+	if base == "" {
+		return "", "", fmt.Errorf("failed to determine config directory")
+	}
+	return filepath.Join(base, "hooks"), filepath.Join(base, "template"), nil
+}
+
+func installGlobal(template bool) error {
+	hooksDir, templateDir, err := globalHookDirs()
+	if err != nil {
+		return err
+	}
+
+	if template {
+		if current := git.GetGlobalConfig("init.templateDir"); current != "" && current != templateDir {
+			return fmt.Errorf("init.templateDir is already set to %s", current)
+		}
+		hookPath, err := hook.InstallTemplate(templateDir)
+		if err != nil {
+			return err
+		}
+		if err := git.SetGlobalConfig("init.templateDir", templateDir); err != nil {
+			return err
+		}
+		fmt.Printf("Git hook template installed at %s\n", hookPath)
+		fmt.Println("New clones get the hook; run 'git init' in an existing repository to add it there")
+		return nil
+	}
+
+	if current := git.GetGlobalConfig("core.hooksPath"); current != "" && current != hooksDir {
+		return fmt.Errorf("core.hooksPath is already set to %s globally", current)
+	}
+	if err := hook.InstallGlobal(hooksDir); err != nil {
+		return err
+	}
+	if err := git.SetGlobalConfig("core.hooksPath", hooksDir); err != nil {
+		return err
+	}
+
+	fmt.Printf("Global git hooks installed at %s\n", hooksDir)
+	fmt.Println("Repository hooks in .git/hooks still run. Set 'enabled: false' in .autocommit.yml to opt a repository out")
+	return nil
+}
+
+func uninstallGlobal() error {
+	hooksDir, templateDir, err := globalHookDirs()
+	if err != nil {
+		return err
+	}
+
+	removed := false
+	if git.GetGlobalConfig("core.hooksPath") == hooksDir {
+		if err := git.UnsetGlobalConfig("core.hooksPath"); err != nil {
+			return err
+		}
+		if err := hook.UninstallGlobal(hooksDir); err != nil {
+			return err
+		}
+		fmt.Printf("Global git hooks removed from %s\n", hooksDir)
+		removed = true
+	}
+	if git.GetGlobalConfig("init.templateDir") == templateDir {
+		if err := git.UnsetGlobalConfig("init.templateDir"); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(templateDir); err != nil {
+			return err
+		}
+		fmt.Printf("Git hook template removed from %s\n", templateDir)
+		removed = true
+	}
+
+	if !removed {
+		fmt.Println("No global hooks installed")
+	}
+	return nil
+}
+
+func printGlobalStatus() {
+	hooksDir, templateDir, err := globalHookDirs()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	switch current := git.GetGlobalConfig("core.hooksPath"); {
+	case current == "":
+		fmt.Println("core.hooksPath: not set")
+	case current == hooksDir && hook.IsGlobal(hooksDir):
+		fmt.Printf("core.hooksPath: %s (AutoCommit global hooks installed)\n", current)
+	case current == hooksDir:
+		fmt.Printf("core.hooksPath: %s (AutoCommit hooks missing, run 'autocommit hook install --global')\n", current)
+	default:
+		fmt.Printf("core.hooksPath: %s (not managed by AutoCommit)\n", current)
+	}
+
+	switch current := git.GetGlobalConfig("init.templateDir"); {
+	case current == "":
+		fmt.Println("init.templateDir: not set")
+	case current == templateDir:
+		fmt.Printf("init.templateDir: %s (AutoCommit template installed)\n", current)
+	default:
+		fmt.Printf("init.templateDir: %s (not managed by AutoCommit)\n", current)
+	}
+
+	if cfg, err := config.Load(); err == nil && !cfg.Enabled {
+		fmt.Println("This repository opts out with 'enabled: false'")
+	}
+}
+
+// runHook does what the installed hook script does: it keeps messages
+// that already have content and otherwise generates one in hook mode
+func runHook(cmd *cobra.Command, args []string) error {
+	msgFile := args[0]
+
+	// pre-commit passes the source in the environment instead
+	source := os.Getenv("PRE_COMMIT_COMMIT_MSG_SOURCE")
+	if len(args) > 1 {
+		source = args[1]
+	}
+
+	// Keep messages from -m, -F, templates, merges, squashes and amends
+	if source != "" {
+		return nil
+	}
+	if content, err := os.ReadFile(msgFile); err == nil {
+		firstLine, _, _ := strings.Cut(string(content), "\n")
+		if strings.TrimSpace(firstLine) != "" && !strings.HasPrefix(firstLine, "#") {
+			return nil
+		}
+	}
+
+	// Carry over --provider, --model and the other root flags
+	generateCmd.Flags().AddFlagSet(cmd.InheritedFlags())
+	generateCmd.Flags().Set("hook-mode", "true")
+	generateCmd.Flags().Set("output", msgFile)
+	return runGenerate(generateCmd, nil)
 }
 
-func isOurHook(content string) bool {
-	return strings.Contains(content, "autocommit")
+func configuredState(configured bool) string {
+	if configured {
+		return "autocommit configured"
+	}
+	return "autocommit not configured"
 }
@@ -7,6 +7,8 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/hook"
 	"github.com/josinSbazin/AutoCommit/internal/ui"
 )
 
@@ -78,10 +80,10 @@ func runInit(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Config saved to %s\n", configPath)
 
 	if ui.Confirm("Install git hook?") {
-		if err := installHook(); err != nil {
+		if hookPath, err := installHook(); err != nil {
 			ui.PrintWarning("Failed to install hook: " + err.Error())
 		} else {
-			fmt.Println("Git hook installed")
+			fmt.Printf("Git hook installed at %s\n", hookPath)
 		}
 	}
 
@@ -91,34 +93,12 @@ func runInit(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func installHook() error {
-	hookPath := ".git/hooks/prepare-commit-msg"
-
-	if _, err := os.Stat(".git"); os.IsNotExist(err) {
-		return fmt.Errorf("not a git repository")
-	}
-
-	if err := os.MkdirAll(".git/hooks", 0755); err != nil {
-		return err
+// installHook adds the hook block to the directory git runs hooks from
+// and returns the hook's path
+func installHook() (string, error) {
+	dir, err := git.GetHookDir()
+	if err != nil {
+		return "", err
 	}
-
-	hookContent := `#!/bin/sh
-COMMIT_MSG_FILE=$1
-COMMIT_SOURCE=$2
-
-if [ -n "$COMMIT_SOURCE" ]; then
-    exit 0
-fi
-
-if [ -s "$COMMIT_MSG_FILE" ]; then
-    first_line=$(head -n1 "$COMMIT_MSG_FILE")
-    if [ -n "$first_line" ] && ! echo "$first_line" | grep -q "^#"; then
-        exit 0
-    fi
-fi
-
-autocommit generate --hook-mode --output "$COMMIT_MSG_FILE"
-`
-
-	return os.WriteFile(hookPath, []byte(hookContent), 0755)
+	return hook.Install(dir.Path, hook.ModeBlock)
 }
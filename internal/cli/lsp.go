@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/josinSbazin/AutoCommit/internal/lsp"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server for commit message buffers",
+	Long: `Run a language server over stdio for COMMIT_EDITMSG and similar buffers.
+It reports lint diagnostics from the configured rules, completes allowed
+Conventional Commits types and scopes, and offers code actions that
+generate or regenerate the message from the staged changes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return lsp.NewServer(generateInDir).Run(context.Background(), os.Stdin, os.Stdout)
+	},
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/mcp"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Run a Model Context Protocol server for coding agents",
+	Long: `Run a Model Context Protocol server over stdio for the repository in the
+current directory. Agents get the tools get_staged_diff,
+generate_commit_message, lint_commit_message, create_commit and
+get_commit_history, which follow the rules in .autocommit.yml.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		repo, err := git.Open(".", cfg.GitBackend)
+		if err != nil {
+			return err
+		}
+		git.SetDefault(repo)
+
+		return mcp.NewServer(versionStr, ".", generateInDir).Run(context.Background(), os.Stdin, os.Stdout)
+	},
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/message"
+)
+
+// generateResult is the machine-readable output of generate --format json
+type generateResult struct {
+	Message    string            `json:"message"`
+	Subject    string            `json:"subject"`
+	Body       string            `json:"body"`
+	Trailers   []message.Trailer `json:"trailers"`
+	Provider   string            `json:"provider"`
+	Model      string            `json:"model"`
+	Usage      usageResult       `json:"usage"`
+	LatencyMs  int64             `json:"latency_ms"`
+	Cached     bool              `json:"cached"`
+	Validation validationResult  `json:"validation"`
+	Stats      diffStatsResult   `json:"stats"`
+	Files      []fileResult      `json:"files"`
+}
+
+type usageResult struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost_usd"`
+	Unpriced         bool    `json:"unpriced,omitempty"`
+}
+
+type validationResult struct {
+	Valid  bool            `json:"valid"`
+	Issues []message.Issue `json:"issues"`
+}
+
+type diffStatsResult struct {
+	FilesChanged int  `json:"files_changed"`
+	Additions    int  `json:"additions"`
+	Deletions    int  `json:"deletions"`
+	Binary       bool `json:"binary"`
+}
+
+type fileResult struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty"`
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary"`
+}
+
+func newGenerateResult(cfg *config.Config, diff *git.DiffResult, gen *generation) generateResult {
+	text := strings.TrimSpace(gen.Text)
+	msg := message.Parse(text)
+	issues := message.Validate(cfg, text)
+
+	result := generateResult{
+		Message:  text,
+		Subject:  msg.Subject,
+		Body:     msg.Body,
+		Trailers: msg.Trailers,
+		Provider: gen.Provider,
+		Model:    gen.Model,
+		Usage: usageResult{
+			PromptTokens:     gen.Usage.PromptTokens,
+			CompletionTokens: gen.Usage.CompletionTokens,
+			TotalTokens:      gen.Usage.Total(),
+			Cost:             gen.Cost,
+			Unpriced:         gen.Unpriced,
+		},
+		LatencyMs: gen.Latency.Milliseconds(),
+		Cached:    gen.Cached,
+		Validation: validationResult{
+			Valid:  !message.HasErrors(issues),
+			Issues: issues,
+		},
+		Stats: diffStatsResult{
+			FilesChanged: diff.Stats.FilesChanged,
+			Additions:    diff.Stats.Additions,
+			Deletions:    diff.Stats.Deletions,
+			Binary:       diff.IsBinary,
+		},
+		Files: make([]fileResult, 0, len(diff.Files)),
+	}
+
+	if result.Trailers == nil {
+		result.Trailers = []message.Trailer{}
+	}
+	if result.Validation.Issues == nil {
+		result.Validation.Issues = []message.Issue{}
+	}
+
+	for _, f := range diff.Files {
+		result.Files = append(result.Files, fileResult{
+			Path:      f.Path,
+			OldPath:   f.OldPath,
+			Status:    f.Status,
+			Additions: f.Additions,
+			Deletions: f.Deletions,
+			Binary:    f.IsBinary,
+		})
+	}
+
+	return result
+}
+
+// writeJSONResult writes v as indented JSON to path, or to stdout if path is empty
+func writeJSONResult(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+	data = append(data, '\n')
+
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
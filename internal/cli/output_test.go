@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/provider"
+)
+
+func TestGenerateResultArrays(t *testing.T) {
+	gen := &generation{Result: &provider.Result{Text: "feat: add login"}, Provider: "openai"}
+	data, err := json.Marshal(newGenerateResult(config.Default(), &git.DiffResult{}, gen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"files":[]`, `"trailers":[]`, `"issues":[]`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("result lacks %s: %s", want, data)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/josinSbazin/AutoCommit/internal/cache"
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/hook"
+	"github.com/josinSbazin/AutoCommit/internal/prefill"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
+	"github.com/josinSbazin/AutoCommit/internal/provider"
+)
+
+var prefillCmd = &cobra.Command{
+	Use:   "prefill",
+	Short: "Generate a message for the staged changes ahead of git commit",
+	Long: `Generate a message for the staged changes and store it under the hash
+of the staged tree, so the prepare-commit-msg hook can use it without
+waiting for the provider. 'autocommit hook install --prefill' runs this in
+the background whenever the index changes. Needs 'behavior.prefill: true'.
+
+The job first waits for the index to settle and gives up when it changed,
+since a later job answers for the new content. It also stops a request
+that is under way once the index changes.`,
+	RunE: runPrefill,
+}
+
+func init() {
+	prefillCmd.Flags().Duration("delay", prefill.Delay, "how long the index must stay unchanged before generating")
+}
+
+func runPrefill(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.Enabled || !cfg.Behavior.Prefill {
+		return nil
+	}
+
+	if p, _ := cmd.Flags().GetString("provider"); p != "" {
+		cfg.Provider = p
+	}
+	if m, _ := cmd.Flags().GetString("model"); m != "" {
+		cfg.Model = m
+	}
+
+	// Reading the repository may refresh the index, which runs
+	// post-index-change again; the hook skips prefill while this is set
+	os.Setenv(hook.PrefillEnv, "1")
+
+	repo, err := git.Open(".", cfg.GitBackend)
+	if err != nil {
+		return err
+	}
+	git.SetDefault(repo)
+
+	tree, err := git.GetIndexTree()
+	if err != nil {
+		return err
+	}
+
+	// Every git add runs the hook; only a tree that stays staged is worth
+	// a request
+	delay, _ := cmd.Flags().GetDuration("delay")
+	time.Sleep(delay)
+	if !isIndexTree(tree) {
+		return nil
+	}
+
+	diff, err := repo.StagedDiff()
+	if err != nil {
+		return fmt.Errorf("failed to get diff: %w", err)
+	}
+	if diff.IsEmpty() {
+		return nil
+	}
+
+	history, _ := repo.History(cfg.Context.HistoryCount)
+	branch, _ := repo.Branch()
+	conversation, err := prompt.NewBuilder(cfg).Build(diff, history, branch)
+	if err != nil {
+		return err
+	}
+
+	key := prefillKey(cfg, conversation)
+	if _, ok := prefill.Get(tree, key); ok {
+		return nil
+	}
+
+	unlock, err := prefill.Lock(tree, key)
+	if err == prefill.ErrRunning {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	generate, err := newGenerator(cfg, ".", conversation)
+	if err != nil {
+		return err
+	}
+
+	// Building the prompt takes a while; check again before paying
+	if !isIndexTree(tree) {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(provider.WithDiff(context.Background(), diff))
+	defer cancel()
+	go cancelWhenStale(ctx, cancel, tree)
+
+	started := time.Now()
+	gen, err := generate(ctx, true)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate message: %w", err)
+	}
+
+	return prefill.Put(tree, prefill.Entry{
+		Created:  started.UTC(),
+		Key:      key,
+		Provider: gen.Provider,
+		Model:    gen.Model,
+		Text:     gen.Text,
+		Usage:    gen.Usage,
+	})
+}
+
+// isIndexTree reports whether tree is still what is staged
+func isIndexTree(tree string) bool {
+	current, err := git.GetIndexTree()
+	return err == nil && current == tree
+}
+
+// cancelWhenStale cancels a job once the index no longer holds tree,
+// since nobody will commit what it generates a message for
+func cancelWhenStale(ctx context.Context, cancel context.CancelFunc, tree string) {
+	ticker := time.NewTicker(prefill.Delay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !isIndexTree(tree) {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// prefilled returns the message a prefill job generated for the staged
+// tree, waiting for a running job up to the hook deadline. It returns nil
+// when there is none, so the caller generates the message itself.
+func prefilled(ctx context.Context, cfg *config.Config, conversation prompt.Prompt) (*generation, error) {
+	tree, err := git.GetIndexTree()
+	if err != nil {
+		return nil, nil
+	}
+	key := prefillKey(cfg, conversation)
+
+	entry, ok := prefill.Get(tree, key)
+	if !ok && prefill.Running(tree, key) {
+		started := time.Now()
+		if err := prefill.Wait(ctx, tree, key); err != nil {
+			return nil, fmt.Errorf("timed out after %s waiting for prefill", time.Since(started).Round(time.Second))
+		}
+		entry, ok = prefill.Get(tree, key)
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &generation{
+		Result:   &provider.Result{Text: entry.Text, Usage: entry.Usage},
+		Provider: entry.Provider,
+		Model:    entry.Model,
+		Cached:   true,
+		Prompt:   conversation,
+	}, nil
+}
+
+// prefillKey identifies the prompt and the configured provider, so the
+// hook can look up a prefilled message without creating a provider
+func prefillKey(cfg *config.Config, conversation prompt.Prompt) string {
+	return cache.Key(cfg.Provider, cfg.Model, requestParams(cfg), conversationKey(conversation))
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
+	"github.com/spf13/cobra"
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Inspect the prompt sent to providers",
+}
+
+var promptShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Render the prompt for the staged changes",
+	Long: `Render the prompt for the staged changes, exactly as it would be sent
+to the provider: the system prompt, then the user message. Use --template
+to print the template itself.`,
+	RunE: runPromptShow,
+}
+
+func init() {
+	promptShowCmd.Flags().Bool("template", false, "Print the template source instead of the rendered prompt")
+	promptCmd.AddCommand(promptShowCmd)
+}
+
+func runPromptShow(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	builder := prompt.NewBuilder(cfg)
+
+	if showTemplate, _ := cmd.Flags().GetBool("template"); showTemplate {
+		text, origin, err := builder.Source()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# Template: %s\n%s", origin, text)
+		return nil
+	}
+
+	repo, err := git.Open(".", cfg.GitBackend)
+	if err != nil {
+		return err
+	}
+	git.SetDefault(repo)
+
+	diff, err := repo.StagedDiff()
+	if err != nil {
+		return fmt.Errorf("failed to get diff: %w", err)
+	}
+	if diff.IsEmpty() {
+		return fmt.Errorf("no staged changes. Use 'git add' first")
+	}
+
+	history, _ := repo.History(cfg.Context.HistoryCount)
+	branch, _ := repo.Branch()
+	conversation, err := builder.Build(diff, history, branch)
+	if err != nil {
+		return err
+	}
+	if err := builder.Fallback(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s, using the built-in prompt\n", err)
+	}
+
+	if conversation.System != "" {
+		fmt.Printf("=== system ===\n%s\n\n", conversation.System)
+	}
+	for _, m := range conversation.Messages {
+		fmt.Printf("=== %s ===\n%s\n\n", m.Role, m.Content)
+	}
+	return nil
+}
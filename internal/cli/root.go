@@ -30,6 +30,7 @@ Examples:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runGenerate(cmd, args)
 	},
+	SilenceUsage: true,
 }
 
 func init() {
@@ -37,6 +38,8 @@ func init() {
 	rootCmd.PersistentFlags().StringP("model", "m", "", "Model name")
 	rootCmd.PersistentFlags().StringP("config", "c", "", "Config file path")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Verbose output")
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "Commit without confirmation")
+	rootCmd.PersistentFlags().Bool("no-interactive", false, "Never prompt; fail instead of asking")
 
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(initCmd)
@@ -44,6 +47,13 @@ func init() {
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(hookCmd)
 	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(usageCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(prefillCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(lspCmd)
+	rootCmd.AddCommand(mcpCmd)
+	rootCmd.AddCommand(promptCmd)
 }
 
 func Execute() error {
@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/message"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
+	"github.com/josinSbazin/AutoCommit/internal/provider"
+	"github.com/josinSbazin/AutoCommit/internal/server"
+	"gopkg.in/yaml.v3"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run a background daemon that the CLI, hook and editors use",
+	Long: `Run a long-lived process that keeps providers, with their auth tokens,
+detected local models and open connections, between requests. It serves
+a JSON API over a Unix socket or a localhost port. Requests must send
+the token from the file it prints on start in the ` + server.TokenHeader + `
+header, and a JSON Content-Type:
+
+  GET  /v1/health
+  POST /v1/generate  {"dir", "provider", "model", "prompt", "no_cache"}
+  POST /v1/lint      {"dir", "message"}
+  POST /v1/validate  {"dir", "provider", "model"}
+
+While it runs, 'autocommit' and the git hook send generation requests to
+it. Config files are read on every request, so edits apply right away.`,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().String("address", "", "Unix socket path or localhost host:port (default: server.address)")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	address := server.Address(cfg)
+	if a, _ := cmd.Flags().GetString("address"); a != "" {
+		address = a
+	}
+
+	l, err := server.Listen(address)
+	if err != nil {
+		return err
+	}
+	token, err := server.WriteToken(address)
+	if err != nil {
+		l.Close()
+		return err
+	}
+	defer os.Remove(server.TokenPath(address))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "autocommit serving on %s (token in %s)\n", address, server.TokenPath(address))
+	return server.Serve(ctx, l, newDaemon(), token)
+}
+
+// daemon implements the server API. Providers are kept per distinct
+// config, so GigaChat tokens, Ollama detection and HTTP connections are
+// reused across requests.
+type daemon struct {
+	mu        sync.Mutex
+	providers map[string]provider.Provider
+}
+
+func newDaemon() *daemon {
+	return &daemon{providers: map[string]provider.Provider{}}
+}
+
+// config loads the config a CLI run in dir would use
+func (d *daemon) config(dir, providerName, model string) (*config.Config, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("dir is required")
+	}
+	cfg, err := config.LoadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if providerName != "" {
+		cfg.Provider = providerName
+	}
+	if model != "" {
+		cfg.Model = model
+	}
+	return cfg, nil
+}
+
+// provider returns the provider for cfg, creating it on first use
+func (d *daemon) provider(cfg *config.Config) (provider.Provider, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if prov, ok := d.providers[key]; ok {
+		return prov, nil
+	}
+	prov, err := provider.Get(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider: %w", err)
+	}
+	d.providers[key] = prov
+	return prov, nil
+}
+
+func (d *daemon) Generate(ctx context.Context, req server.GenerateRequest) (*server.GenerateResponse, error) {
+	cfg, err := d.config(req.Dir, req.Provider, req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := git.Open(req.Dir, cfg.GitBackend)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := repo.StagedDiff()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get diff: %w", err)
+	}
+	if diff.IsEmpty() {
+		return nil, fmt.Errorf("no staged changes. Use 'git add' first")
+	}
+
+	var conversation prompt.Prompt
+	if req.Prompt != nil {
+		conversation = *req.Prompt
+	} else {
+		history, _ := repo.History(cfg.Context.HistoryCount)
+		branch, _ := repo.Branch()
+		if conversation, err = prompt.NewBuilder(cfg).Build(diff, history, branch); err != nil {
+			return nil, err
+		}
+	}
+
+	prov, err := d.provider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	gen, err := generateMessage(provider.WithDiff(ctx, diff), cfg, prov, conversation, !req.NoCache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate message: %w", err)
+	}
+
+	issues := message.Validate(cfg, gen.Text)
+	if issues == nil {
+		issues = []message.Issue{}
+	}
+	return &server.GenerateResponse{
+		Message:   strings.TrimSpace(gen.Text),
+		Provider:  gen.Provider,
+		Model:     gen.Model,
+		Usage:     gen.Usage,
+		Cost:      gen.Cost,
+		Unpriced:  gen.Unpriced,
+		LatencyMs: gen.Latency.Milliseconds(),
+		Cached:    gen.Cached,
+		Issues:    issues,
+	}, nil
+}
+
+func (d *daemon) Lint(ctx context.Context, req server.LintRequest) (*server.LintResponse, error) {
+	cfg, err := d.config(req.Dir, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	issues := message.Validate(cfg, req.Message)
+	if issues == nil {
+		issues = []message.Issue{}
+	}
+	return &server.LintResponse{
+		Valid:  !message.HasErrors(issues),
+		Issues: issues,
+	}, nil
+}
+
+func (d *daemon) Validate(ctx context.Context, req server.ValidateRequest) (*server.ValidateResponse, error) {
+	cfg, err := d.config(req.Dir, req.Provider, req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	prov, err := d.provider(cfg)
+	if err != nil {
+		return &server.ValidateResponse{Provider: cfg.Provider, Model: cfg.Model, Error: err.Error()}, nil
+	}
+
+	resp := &server.ValidateResponse{Provider: prov.Name(), Model: prov.Model(), Valid: true}
+	if err := prov.Validate(); err != nil {
+		resp.Valid = false
+		resp.Error = err.Error()
+	}
+	return resp, nil
+}
+
+// daemonGenerate asks a running daemon for a message, sending the prompt
+// built here so both sides agree on it
+func daemonGenerate(ctx context.Context, client *server.Client, cfg *config.Config, dir string, conversation prompt.Prompt, useCache bool) (*generation, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// As with local generation, Ctrl-C cancels only the request
+	genCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	started := time.Now()
+	resp, err := client.Generate(genCtx, server.GenerateRequest{
+		Dir:      dir,
+		Provider: cfg.Provider,
+		Model:    cfg.Model,
+		Prompt:   &conversation,
+		NoCache:  !useCache,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(genCtx.Err(), context.DeadlineExceeded):
+			return nil, fmt.Errorf("timed out after %s", time.Since(started).Round(time.Second))
+		case genCtx.Err() != nil:
+			return nil, errInterrupted
+		}
+		return nil, err
+	}
+
+	return &generation{
+		Result:   &provider.Result{Text: resp.Message, Usage: resp.Usage},
+		Provider: resp.Provider,
+		Model:    resp.Model,
+		Latency:  time.Duration(resp.LatencyMs) * time.Millisecond,
+		Cost:     resp.Cost,
+		Unpriced: resp.Unpriced,
+		Cached:   resp.Cached,
+		Prompt:   conversation,
+	}, nil
+}
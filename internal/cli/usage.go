@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/josinSbazin/AutoCommit/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show token usage and estimated cost",
+	RunE:  runUsage,
+}
+
+func init() {
+	usageCmd.Flags().Int("days", 30, "Number of days to include")
+	usageCmd.Flags().String("by", "", "Group by: day, provider or repo (default: all)")
+}
+
+func runUsage(cmd *cobra.Command, args []string) error {
+	days, _ := cmd.Flags().GetInt("days")
+	by, _ := cmd.Flags().GetString("by")
+
+	groups := []string{"day", "provider", "repo"}
+	if by != "" {
+		switch by {
+		case "day", "provider", "repo":
+			groups = []string{by}
+		default:
+			return fmt.Errorf("unknown grouping: %s (use day, provider or repo)", by)
+		}
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	records, err := usage.Load(since)
+	if err != nil {
+		return fmt.Errorf("failed to read usage log: %w", err)
+	}
+
+	if len(records) == 0 {
+		fmt.Printf("No usage recorded in the last %d days\n", days)
+		return nil
+	}
+
+	for i, group := range groups {
+		if i > 0 {
+			fmt.Println()
+		}
+		printUsageTable(group, usage.Summarize(records, group))
+	}
+
+	var total usage.Total
+	for _, r := range records {
+		total.Add(r)
+	}
+
+	cost := total.CostString()
+	if total.Unpriced < total.Requests {
+		cost = "~$" + cost
+	}
+	fmt.Println()
+	fmt.Printf("Total (last %d days): %d requests, %d tokens, cost %s\n",
+		days, total.Requests, total.PromptTokens+total.CompletionTokens, cost)
+	fmt.Printf("Log: %s\n", usage.LogPath())
+
+	return nil
+}
+
+func printUsageTable(group string, totals []usage.Total) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tREQUESTS\tPROMPT\tCOMPLETION\tCOST (USD)\n", group)
+	for _, t := range totals {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", t.Label, t.Requests, t.PromptTokens, t.CompletionTokens, t.CostString())
+	}
+	w.Flush()
+}
@@ -5,18 +5,25 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds all configuration options
 type Config struct {
+	// Enabled false turns the git hook off for a repository
+	Enabled bool `yaml:"enabled"`
+
 	// LLM Provider settings
 	Provider  string `yaml:"provider"`
 	Model     string `yaml:"model"`
 	Endpoint  string `yaml:"endpoint,omitempty"`
 	APIKeyEnv string `yaml:"api_key_env,omitempty"`
 
+	// Extra request parameters for exec: plugin providers
+	PluginParams map[string]any `yaml:"plugin_params,omitempty"`
+
 	// YandexGPT specific
 	FolderID string `yaml:"folder_id,omitempty"`
 
@@ -39,8 +46,31 @@ type Config struct {
 	// Behavior
 	Behavior BehaviorConfig `yaml:"behavior"`
 
+	// Usage accounting
+	Usage UsageConfig `yaml:"usage"`
+
+	// Spending and rate limits
+	Budget BudgetConfig `yaml:"budget,omitempty"`
+
+	// Git access: exec runs the git binary, go reads the repository
+	// directly. Empty uses the git binary when it is installed.
+	GitBackend string `yaml:"git_backend,omitempty"`
+
+	// Response cache
+	Cache CacheConfig `yaml:"cache"`
+
+	// Network timeouts
+	Timeouts TimeoutConfig `yaml:"timeouts"`
+
+	// Background daemon started by 'autocommit serve'
+	Server ServerConfig `yaml:"server"`
+
 	// Custom instructions for LLM
 	Instructions string `yaml:"instructions,omitempty"`
+
+	// PromptTemplate is a text/template file that replaces the built-in
+	// prompt. Relative paths are resolved from the config file's directory.
+	PromptTemplate string `yaml:"prompt_template,omitempty"`
 }
 
 // ConventionalConfig for Conventional Commits style
@@ -48,14 +78,18 @@ type ConventionalConfig struct {
 	RequireScope  bool     `yaml:"require_scope"`
 	AllowedTypes  []string `yaml:"allowed_types,omitempty"`
 	AllowedScopes []string `yaml:"allowed_scopes,omitempty"`
+	// DepsType is used for dependency-only commits: build or chore
+	DepsType string `yaml:"deps_type,omitempty"`
 }
 
 // ContextConfig for context gathering
 type ContextConfig struct {
-	IncludeHistory   bool `yaml:"include_history"`
-	HistoryCount     int  `yaml:"history_count"`
-	IncludeBranch    bool `yaml:"include_branch"`
-	IncludeDiffStats bool `yaml:"include_diff_stats"`
+	IncludeHistory     bool `yaml:"include_history"`
+	HistoryCount       int  `yaml:"history_count"`
+	IncludeBranch      bool `yaml:"include_branch"`
+	IncludeDiffStats   bool `yaml:"include_diff_stats"`
+	IncludeGoSemantics bool `yaml:"include_go_semantics"`
+	SummarizeDeps      bool `yaml:"summarize_deps"`
 }
 
 // BehaviorConfig for runtime behavior
@@ -63,11 +97,86 @@ type BehaviorConfig struct {
 	AutoStage           bool `yaml:"auto_stage"`
 	Interactive         bool `yaml:"interactive"`
 	ConfirmBeforeCommit bool `yaml:"confirm_before_commit"`
+	// Prefill lets the hook use messages generated in the background
+	// by 'autocommit prefill'
+	Prefill bool `yaml:"prefill"`
+}
+
+// UsageConfig for token usage and cost accounting
+type UsageConfig struct {
+	Log     bool                  `yaml:"log"`
+	Pricing map[string]ModelPrice `yaml:"pricing,omitempty"`
+}
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+}
+
+// BudgetConfig for spending and rate limits. Zero values disable a limit.
+type BudgetConfig struct {
+	MaxTokensPerRequest  int     `yaml:"max_tokens_per_request,omitempty"`
+	MaxCostPerDay        float64 `yaml:"max_cost_per_day,omitempty"` // USD
+	MaxRequestsPerMinute int     `yaml:"max_requests_per_minute,omitempty"`
+
+	// OnExceed is "refuse" (default) or "fallback"
+	OnExceed         string `yaml:"on_exceed,omitempty"`
+	FallbackProvider string `yaml:"fallback_provider,omitempty"`
+	FallbackModel    string `yaml:"fallback_model,omitempty"`
+}
+
+// CacheConfig for the on-disk response cache
+type CacheConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	TTL       time.Duration `yaml:"ttl"`
+	MaxSizeMB int           `yaml:"max_size_mb"`
+}
+
+// TimeoutConfig for provider requests
+type TimeoutConfig struct {
+	Connect  time.Duration `yaml:"connect"`
+	Response time.Duration `yaml:"response"`
+	// Hook bounds the whole generation in --hook-mode
+	Hook time.Duration `yaml:"hook"`
+	// Providers overrides connect and response per provider name
+	Providers map[string]ProviderTimeout `yaml:"providers,omitempty"`
+}
+
+// ServerConfig for the background daemon
+type ServerConfig struct {
+	// Enabled false stops the CLI and hook from using a running daemon
+	Enabled bool `yaml:"enabled"`
+	// Address is a Unix socket path or a localhost host:port. Empty means
+	// autocommit.sock in the global config directory. Only read from the
+	// global config.
+	Address string `yaml:"address,omitempty"`
+}
+
+// ProviderTimeout overrides timeouts for a single provider
+type ProviderTimeout struct {
+	Connect  time.Duration `yaml:"connect,omitempty"`
+	Response time.Duration `yaml:"response,omitempty"`
+}
+
+// ForProvider returns the connect and response timeouts for a provider
+func (t TimeoutConfig) ForProvider(name string) (connect, response time.Duration) {
+	connect, response = t.Connect, t.Response
+	if o, ok := t.Providers[name]; ok {
+		if o.Connect > 0 {
+			connect = o.Connect
+		}
+		if o.Response > 0 {
+			response = o.Response
+		}
+	}
+	return connect, response
 }
 
 // Default returns default configuration
 func Default() *Config {
 	return &Config{
+		Enabled:          true,
 		Provider:         "",
 		Model:            "",
 		Style:            "conventional",
@@ -79,43 +188,105 @@ func Default() *Config {
 		Conventional: ConventionalConfig{
 			RequireScope: false,
 			AllowedTypes: []string{"feat", "fix", "docs", "style", "refactor", "test", "chore", "perf", "ci", "build"},
+			DepsType:     "build",
 		},
 		Context: ContextConfig{
-			IncludeHistory:   true,
-			HistoryCount:     10,
-			IncludeBranch:    true,
-			IncludeDiffStats: true,
+			IncludeHistory:     true,
+			HistoryCount:       10,
+			IncludeBranch:      true,
+			IncludeDiffStats:   true,
+			IncludeGoSemantics: true,
+			SummarizeDeps:      true,
 		},
 		Behavior: BehaviorConfig{
 			AutoStage:           false,
 			Interactive:         true,
 			ConfirmBeforeCommit: true,
 		},
+		Usage: UsageConfig{
+			Log: true,
+		},
+		Cache: CacheConfig{
+			Enabled:   true,
+			TTL:       24 * time.Hour,
+			MaxSizeMB: 10,
+		},
+		Timeouts: TimeoutConfig{
+			Connect:  10 * time.Second,
+			Response: 60 * time.Second,
+			Hook:     15 * time.Second,
+		},
+		Server: ServerConfig{
+			Enabled: true,
+		},
 	}
 }
 
+// ProjectPromptTemplate is picked up as prompt_template when it exists
+const ProjectPromptTemplate = ".autocommit/prompt.tmpl"
+
 var loadedSources []string
 
 func Load() (*Config, error) {
-	loadedSources = []string{}
+	cfg, sources := load(".")
+	loadedSources = sources
+	return cfg, nil
+}
+
+// LoadDir is Load with the project config read from dir instead of the
+// current directory. It leaves GetLoadedSources alone, so a daemon can
+// call it concurrently.
+func LoadDir(dir string) (*Config, error) {
+	cfg, _ := load(dir)
+	return cfg, nil
+}
+
+func load(dir string) (*Config, []string) {
+	sources := []string{}
 	cfg := Default()
 
 	if globalPath := getGlobalConfigPath(); globalPath != "" {
 		if err := loadFile(globalPath, cfg); err == nil {
-			loadedSources = append(loadedSources, globalPath)
+			sources = append(sources, globalPath)
 		}
 	}
 
-	// 2. Load project config
-	projectPath := ".autocommit.yml"
+	// 2. Load project config. A project prompt template overrides the
+	// global one unless the project config names another.
+	global := *cfg
+	projectTemplate := filepath.Join(dir, ProjectPromptTemplate)
+	if _, err := os.Stat(projectTemplate); err == nil {
+		cfg.PromptTemplate = projectTemplate
+	}
+	projectPath := filepath.Join(dir, ".autocommit.yml")
 	if err := loadFile(projectPath, cfg); err == nil {
-		loadedSources = append(loadedSources, projectPath)
+		sources = append(sources, projectPath)
 	}
+	restrictProject(cfg, &global)
 
 	// 3. Load from environment
 	loadEnv(cfg)
 
-	return cfg, nil
+	return cfg, sources
+}
+
+// restrictProject undoes project settings that a cloned repository must
+// not control, because they decide where the staged diff is sent or
+// which binary runs on every commit
+func restrictProject(cfg, global *Config) {
+	cfg.Server.Address = global.Server.Address
+	if isPluginPath(cfg.Provider) {
+		cfg.Provider = global.Provider
+	}
+	if isPluginPath(cfg.Budget.FallbackProvider) {
+		cfg.Budget.FallbackProvider = global.Budget.FallbackProvider
+	}
+}
+
+// isPluginPath reports whether provider names an executable by path, as
+// in exec:./scripts/llm
+func isPluginPath(provider string) bool {
+	return strings.ContainsAny(provider, `/\`)
 }
 
 // loadFile loads config from a YAML file
@@ -124,7 +295,15 @@ func loadFile(path string, cfg *Config) error {
 	if err != nil {
 		return err
 	}
-	return yaml.Unmarshal(data, cfg)
+
+	promptTemplate := cfg.PromptTemplate
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return err
+	}
+	if cfg.PromptTemplate != promptTemplate && cfg.PromptTemplate != "" && !filepath.IsAbs(cfg.PromptTemplate) {
+		cfg.PromptTemplate = filepath.Join(filepath.Dir(path), cfg.PromptTemplate)
+	}
+	return nil
 }
 
 // loadEnv loads config from environment variables
@@ -0,0 +1,90 @@
+package deps
+
+import (
+	"strings"
+
+	"github.com/josinSbazin/AutoCommit/internal/git"
+)
+
+// Analyze parses every staged manifest in the diff against HEAD
+func Analyze(diff *git.DiffResult) []Change {
+	var changes []Change
+	for _, f := range diff.Files {
+		if f.IsBinary || !IsManifest(f.Path) {
+			continue
+		}
+		oldData, newData := diff.Images(f)
+		if c, err := Diff(f.Path, oldData, newData); err == nil {
+			changes = append(changes, c...)
+		}
+	}
+	return changes
+}
+
+// ForManifest returns the changes that belong to one manifest
+func ForManifest(changes []Change, manifest string) []Change {
+	var result []Change
+	for _, c := range changes {
+		if c.Manifest == manifest {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// OnlyDependencyLines reports whether every changed line of a manifest's
+// diff chunk is explained by the given dependency changes, so the chunk
+// can be replaced by the summary without losing information
+func OnlyDependencyLines(chunk string, changes []Change) bool {
+	if len(changes) == 0 {
+		return false
+	}
+
+	for _, line := range strings.Split(chunk, "\n") {
+		if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+			continue
+		}
+		if !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		content := strings.TrimSpace(line[1:])
+		if strings.Trim(content, `{}[](),"'`) == "" || content == "require (" {
+			continue
+		}
+		if !mentionsChange(content, changes) {
+			return false
+		}
+	}
+	return true
+}
+
+func mentionsChange(line string, changes []Change) bool {
+	for _, c := range changes {
+		name := c.Name
+		// Maven coordinates appear as separate groupId/artifactId elements
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		if strings.Contains(strings.ToLower(line), strings.ToLower(name)) {
+			return true
+		}
+		if c.From != "" && strings.Contains(line, c.From) || c.To != "" && strings.Contains(line, c.To) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnlyDependencies reports whether all changed files are manifests or lockfiles
+func OnlyDependencies(files []git.FileChange) bool {
+	if len(files) == 0 {
+		return false
+	}
+	for _, f := range files {
+		if !IsManifest(f.Path) && !IsLockfile(f.Path) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package deps
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Change kinds
+const (
+	Added   = "added"
+	Removed = "removed"
+	Bumped  = "bumped"
+)
+
+// Change is a single dependency change in a manifest
+type Change struct {
+	Manifest string
+	Name     string
+	Kind     string
+	From     string
+	To       string
+}
+
+// parser extracts name -> version pairs from a manifest
+type parser func(data []byte) (map[string]string, error)
+
+var parsers = map[string]parser{
+	"go.mod":           parseGoMod,
+	"package.json":     parsePackageJSON,
+	"requirements.txt": parseRequirements,
+	"Cargo.toml":       parseCargoToml,
+	"pom.xml":          parsePomXML,
+}
+
+// IsManifest reports whether a path is a dependency manifest we can parse
+func IsManifest(p string) bool {
+	_, ok := parsers[path.Base(p)]
+	return ok
+}
+
+// IsLockfile reports whether a path is a generated dependency lockfile
+func IsLockfile(p string) bool {
+	switch path.Base(p) {
+	case "go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml",
+		"Cargo.lock", "poetry.lock", "Pipfile.lock", "composer.lock":
+		return true
+	}
+	return false
+}
+
+// Diff compares the old and new content of a manifest.
+// Either side may be nil for added or deleted files.
+func Diff(manifest string, oldData, newData []byte) ([]Change, error) {
+	parse, ok := parsers[path.Base(manifest)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported manifest: %s", manifest)
+	}
+
+	oldDeps, err := parseOptional(parse, oldData)
+	if err != nil {
+		return nil, err
+	}
+	newDeps, err := parseOptional(parse, newData)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for name, to := range newDeps {
+		from, ok := oldDeps[name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Manifest: manifest, Name: name, Kind: Added, To: to})
+		case from != to:
+			changes = append(changes, Change{Manifest: manifest, Name: name, Kind: Bumped, From: from, To: to})
+		}
+	}
+	for name, from := range oldDeps {
+		if _, ok := newDeps[name]; !ok {
+			changes = append(changes, Change{Manifest: manifest, Name: name, Kind: Removed, From: from})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return kindOrder(changes[i].Kind) < kindOrder(changes[j].Kind)
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes, nil
+}
+
+func parseOptional(parse parser, data []byte) (map[string]string, error) {
+	if len(data) == 0 {
+		return map[string]string{}, nil
+	}
+	return parse(data)
+}
+
+func kindOrder(kind string) int {
+	switch kind {
+	case Bumped:
+		return 0
+	case Added:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Describe returns a short phrase for a change, e.g. "bump x from 1.2 to 1.3"
+func (c Change) Describe() string {
+	switch c.Kind {
+	case Added:
+		if c.To == "" {
+			return "add " + c.Name
+		}
+		return fmt.Sprintf("add %s %s", c.Name, c.To)
+	case Removed:
+		return fmt.Sprintf("remove %s", c.Name)
+	default:
+		return fmt.Sprintf("bump %s from %s to %s", c.Name, c.From, c.To)
+	}
+}
+
+// Summary joins change descriptions into one line
+func Summary(changes []Change) string {
+	parts := make([]string, len(changes))
+	for i, c := range changes {
+		parts[i] = c.Describe()
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,267 @@
+package deps
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/josinSbazin/AutoCommit/internal/git"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name, manifest, old, new, want string
+	}{
+		{
+			name:     "go.mod",
+			manifest: "go.mod",
+			old:      "module x\n\ngo 1.22\n\nrequire (\n\tgithub.com/a/a v1.0.0\n\tgithub.com/b/b v0.3.0 // indirect\n)\n\nrequire github.com/c/c v2.1.0\n",
+			new:      "module x\n\ngo 1.22\n\nrequire (\n\tgithub.com/a/a v1.1.0\n\tgithub.com/d/d v0.1.0\n)\n\nrequire github.com/c/c v2.1.0\n",
+			want:     "bump github.com/a/a from v1.0.0 to v1.1.0, add github.com/d/d v0.1.0, remove github.com/b/b",
+		},
+		{
+			name:     "go.mod without dependency changes",
+			manifest: "go.mod",
+			old:      "module x\n\ngo 1.21\n\nrequire github.com/a/a v1.0.0\n",
+			new:      "module x\n\ngo 1.22\n\ntoolchain go1.22.5\n\nrequire github.com/a/a v1.0.0 // pinned\n",
+		},
+		{
+			name:     "package.json",
+			manifest: "web/package.json",
+			old:      `{"name": "web", "dependencies": {"react": "^18.2.0", "lodash": "^4.17.0"}, "devDependencies": {"vite": "^5.0.0"}}`,
+			new:      `{"name": "web", "dependencies": {"react": "^18.3.1"}, "devDependencies": {"vite": "^5.0.0", "vitest": "^1.6.0"}}`,
+			want:     "bump react from ^18.2.0 to ^18.3.1, add vitest ^1.6.0, remove lodash",
+		},
+		{
+			name:     "package.json scripts only",
+			manifest: "package.json",
+			old:      `{"scripts": {"test": "jest"}, "dependencies": {"react": "^18.2.0"}}`,
+			new:      `{"scripts": {"test": "vitest"}, "dependencies": {"react": "^18.2.0"}}`,
+		},
+		{
+			name:     "requirements.txt",
+			manifest: "requirements.txt",
+			old:      "# web\nDjango==4.2.0\nrequests>=2.28\ncelery[redis]==5.3.0 ; python_version >= '3.8'\n",
+			new:      "# web\ndjango==5.0.1\nrequests>=2.28\nhttpx\n-r dev.txt\n",
+			want:     "bump django from 4.2.0 to 5.0.1, add httpx, remove celery",
+		},
+		{
+			name:     "requirements.txt comments only",
+			manifest: "requirements.txt",
+			old:      "requests==2.31.0\n",
+			new:      "# HTTP client\nrequests==2.31.0  # keep in sync with docs\n",
+		},
+		{
+			name:     "Cargo.toml",
+			manifest: "Cargo.toml",
+			old:      "[package]\nname = \"x\"\nversion = \"0.1.0\"\n\n[dependencies]\nserde = { version = \"1.0\", features = [\"derive\"] }\nrand = \"0.8\"\n\n[dependencies.tokio]\nversion = \"1.35\"\n",
+			new:      "[package]\nname = \"x\"\nversion = \"0.2.0\"\n\n[dependencies]\nserde = { version = \"1.0\", features = [\"derive\"] }\n\n[dev-dependencies]\nproptest = \"1.4\"\n\n[dependencies.tokio]\nversion = \"1.36\"\n",
+			want:     "bump tokio from 1.35 to 1.36, add proptest 1.4, remove rand",
+		},
+		{
+			name:     "Cargo.toml package version only",
+			manifest: "Cargo.toml",
+			old:      "[package]\nversion = \"0.1.0\"\n\n[dependencies]\nrand = \"0.8\"\n",
+			new:      "[package]\nversion = \"0.2.0\"\n\n[dependencies]\nrand = \"0.8\"\n",
+		},
+		{
+			name:     "pom.xml",
+			manifest: "pom.xml",
+			old: `<project><properties><junit.version>5.10.0</junit.version></properties><dependencies>
+<dependency><groupId>org.junit.jupiter</groupId><artifactId>junit-jupiter</artifactId><version>${junit.version}</version></dependency>
+<dependency><groupId>com.google.guava</groupId><artifactId>guava</artifactId><version>32.0.0-jre</version></dependency>
+</dependencies></project>`,
+			new: `<project><properties><junit.version>5.10.2</junit.version></properties><dependencies>
+<dependency><groupId>org.junit.jupiter</groupId><artifactId>junit-jupiter</artifactId><version>${junit.version}</version></dependency>
+<dependency><groupId>org.slf4j</groupId><artifactId>slf4j-api</artifactId><version>2.0.9</version></dependency>
+</dependencies></project>`,
+			want: "bump org.junit.jupiter:junit-jupiter from 5.10.0 to 5.10.2, add org.slf4j:slf4j-api 2.0.9, remove com.google.guava:guava",
+		},
+		{
+			name:     "pom.xml description only",
+			manifest: "pom.xml",
+			old:      `<project><description>old</description><dependencies><dependency><groupId>g</groupId><artifactId>a</artifactId><version>1</version></dependency></dependencies></project>`,
+			new:      `<project><description>new</description><dependencies><dependency><groupId>g</groupId><artifactId>a</artifactId><version>1</version></dependency></dependencies></project>`,
+		},
+		{
+			name:     "new manifest",
+			manifest: "requirements.txt",
+			new:      "flask==3.0.0\n",
+			want:     "add flask 3.0.0",
+		},
+		{
+			name:     "deleted manifest",
+			manifest: "go.mod",
+			old:      "module x\n\nrequire github.com/a/a v1.0.0\n",
+			want:     "remove github.com/a/a",
+		},
+	}
+	for _, tt := range tests {
+		var oldData, newData []byte
+		if tt.old != "" {
+			oldData = []byte(tt.old)
+		}
+		if tt.new != "" {
+			newData = []byte(tt.new)
+		}
+		changes, err := Diff(tt.manifest, oldData, newData)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := Summary(changes); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+		for _, c := range changes {
+			if c.Manifest != tt.manifest {
+				t.Errorf("%s: change for manifest %q", tt.name, c.Manifest)
+			}
+		}
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	if _, err := Diff("build.gradle", nil, []byte("x")); err == nil {
+		t.Error("unsupported manifest accepted")
+	}
+	if _, err := Diff("package.json", []byte(`{}`), []byte(`{"dependencies": `)); err == nil {
+		t.Error("broken package.json accepted")
+	}
+}
+
+func TestOnlyDependencyLines(t *testing.T) {
+	bump := []Change{{Manifest: "go.mod", Name: "github.com/a/a", Kind: Bumped, From: "v1.0.0", To: "v1.1.0"}}
+	maven := []Change{{Manifest: "pom.xml", Name: "org.slf4j:slf4j-api", Kind: Added, To: "2.0.9"}}
+
+	tests := []struct {
+		name    string
+		chunk   string
+		changes []Change
+		want    bool
+	}{
+		{
+			name:    "bump",
+			chunk:   "--- a/go.mod\n+++ b/go.mod\n@@ -3,3 +3,3 @@\n require (\n-\tgithub.com/a/a v1.0.0\n+\tgithub.com/a/a v1.1.0\n )\n",
+			changes: bump,
+			want:    true,
+		},
+		{
+			name:    "bump and go version",
+			chunk:   "--- a/go.mod\n+++ b/go.mod\n@@ -1,5 +1,5 @@\n-go 1.21\n+go 1.22\n-\tgithub.com/a/a v1.0.0\n+\tgithub.com/a/a v1.1.0\n",
+			changes: bump,
+		},
+		{
+			name:    "maven element lines",
+			chunk:   "@@ -1,2 +1,7 @@\n <dependencies>\n+<dependency>\n+<groupId>org.slf4j</groupId>\n+<artifactId>slf4j-api</artifactId>\n+<version>2.0.9</version>\n+</dependency>\n",
+			changes: maven,
+		},
+		{
+			name:  "no changes",
+			chunk: "@@ -1 +1 @@\n-a\n+b\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := OnlyDependencyLines(tt.chunk, tt.changes); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOnlyDependencies(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  bool
+	}{
+		{[]string{"go.mod", "go.sum"}, true},
+		{[]string{"web/package.json", "web/package-lock.json"}, true},
+		{[]string{"go.mod", "main.go"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		var files []git.FileChange
+		for _, p := range tt.paths {
+			files = append(files, git.FileChange{Path: p})
+		}
+		if got := OnlyDependencies(files); got != tt.want {
+			t.Errorf("OnlyDependencies(%q) = %v", tt.paths, got)
+		}
+	}
+}
+
+func stage(t *testing.T, before, after map[string]string) *git.DiffResult {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			full := filepath.Join(dir, name)
+			if content == "" {
+				os.Remove(full)
+				continue
+			}
+			os.MkdirAll(filepath.Dir(full), 0755)
+			if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		run("add", "-A")
+	}
+
+	run("init", "-q")
+	write(before)
+	run("-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init")
+	write(after)
+
+	diff, err := (&git.ExecRepository{Dir: dir}).StagedDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return diff
+}
+
+func TestAnalyze(t *testing.T) {
+	diff := stage(t, map[string]string{
+		"go.mod":           "module x\n\nrequire github.com/a/a v1.0.0\n",
+		"web/package.json": `{"dependencies": {"react": "^18.2.0"}}`,
+		"main.go":          "package main\n",
+	}, map[string]string{
+		"go.mod":           "module x\n\nrequire github.com/a/a v1.1.0\n",
+		"web/package.json": "",
+		"requirements.txt": "flask==3.0.0\n",
+		"main.go":          "package main\n\nfunc main() {}\n",
+	})
+
+	changes := Analyze(diff)
+	got := Summary(ForManifest(changes, "go.mod")) + "; " +
+		Summary(ForManifest(changes, "web/package.json")) + "; " +
+		Summary(ForManifest(changes, "requirements.txt"))
+	want := "bump github.com/a/a from v1.0.0 to v1.1.0; remove react; add flask 3.0.0"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if len(changes) != 3 {
+		t.Errorf("got %d changes, want 3: %+v", len(changes), changes)
+	}
+
+	for _, f := range diff.Files {
+		if f.Path != "go.mod" {
+			continue
+		}
+		if !OnlyDependencyLines(f.DiffChunk, ForManifest(changes, f.Path)) {
+			t.Errorf("go.mod chunk not explained by its changes:\n%s", f.DiffChunk)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package deps
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+)
+
+// parseGoMod reads require directives from a go.mod file
+func parseGoMod(data []byte) (map[string]string, error) {
+	result := make(map[string]string)
+	inRequire := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "require (":
+			inRequire = true
+			continue
+		case inRequire && line == ")":
+			inRequire = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !inRequire:
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			result[fields[0]] = fields[1]
+		}
+	}
+	return result, scanner.Err()
+}
+
+// parsePackageJSON reads all dependency sections from a package.json file
+func parsePackageJSON(data []byte) (map[string]string, error) {
+	var pkg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, section := range []string{"dependencies", "devDependencies", "peerDependencies", "optionalDependencies"} {
+		raw, ok := pkg[section]
+		if !ok {
+			continue
+		}
+		var deps map[string]string
+		if err := json.Unmarshal(raw, &deps); err != nil {
+			continue
+		}
+		for name, version := range deps {
+			result[name] = version
+		}
+	}
+	return result, nil
+}
+
+// parseRequirements reads pinned and ranged packages from requirements.txt
+func parseRequirements(data []byte) (map[string]string, error) {
+	result := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if i := strings.Index(line, ";"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		name, version := line, ""
+		if i := strings.IndexAny(line, "=<>!~ "); i >= 0 {
+			name = strings.TrimSpace(line[:i])
+			version = strings.TrimSpace(line[i:])
+			version = strings.TrimPrefix(version, "==")
+		}
+		if i := strings.Index(name, "["); i >= 0 {
+			name = name[:i]
+		}
+		result[strings.ToLower(name)] = version
+	}
+	return result, scanner.Err()
+}
+
+// parseCargoToml reads dependency tables from Cargo.toml
+func parseCargoToml(data []byte) (map[string]string, error) {
+	result := make(map[string]string)
+	section := ""
+	tableDep := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[] ")
+			tableDep = ""
+			// [dependencies.serde] style tables
+			for _, prefix := range []string{"dependencies.", "dev-dependencies.", "build-dependencies."} {
+				if i := strings.Index(section, prefix); i >= 0 {
+					tableDep = section[i+len(prefix):]
+					result[tableDep] = ""
+				}
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		value = strings.TrimSpace(value)
+
+		if tableDep != "" {
+			if key == "version" {
+				result[tableDep] = strings.Trim(value, `"`)
+			}
+			continue
+		}
+		if !strings.HasSuffix(section, "dependencies") {
+			continue
+		}
+
+		if strings.HasPrefix(value, "{") {
+			result[key] = inlineTableValue(value, "version")
+		} else {
+			result[key] = strings.Trim(value, `"`)
+		}
+	}
+	return result, scanner.Err()
+}
+
+// inlineTableValue extracts a string field from a TOML inline table
+func inlineTableValue(table, field string) string {
+	table = strings.Trim(table, "{} ")
+	for _, part := range strings.Split(table, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if ok && strings.TrimSpace(key) == field {
+			return strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return ""
+}
+
+// parsePomXML reads dependencies from a Maven pom.xml, resolving
+// ${property} versions from the <properties> section
+func parsePomXML(data []byte) (map[string]string, error) {
+	type dependency struct {
+		GroupID    string `xml:"groupId"`
+		ArtifactID string `xml:"artifactId"`
+		Version    string `xml:"version"`
+	}
+	var pom struct {
+		Properties struct {
+			Entries []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"properties"`
+		Dependencies         []dependency `xml:"dependencies>dependency"`
+		DependencyManagement []dependency `xml:"dependencyManagement>dependencies>dependency"`
+	}
+
+	if err := xml.Unmarshal(data, &pom); err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]string)
+	for _, p := range pom.Properties.Entries {
+		props[p.XMLName.Local] = strings.TrimSpace(p.Value)
+	}
+
+	result := make(map[string]string)
+	for _, d := range append(pom.Dependencies, pom.DependencyManagement...) {
+		version := strings.TrimSpace(d.Version)
+		if strings.HasPrefix(version, "${") && strings.HasSuffix(version, "}") {
+			if v, ok := props[version[2:len(version)-1]]; ok {
+				version = v
+			}
+		}
+		result[strings.TrimSpace(d.GroupID)+":"+strings.TrimSpace(d.ArtifactID)] = version
+	}
+	return result, nil
+}
@@ -0,0 +1,205 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// summary is the part of a FileChange both backends must agree on
+type summary struct {
+	Path, OldPath, Status string
+	Additions, Deletions  int
+	IsBinary              bool
+	Kind                  string
+	OldMode, NewMode      string
+	OldHash, NewHash      string
+}
+
+func summarize(diff *DiffResult) []summary {
+	var s []summary
+	for _, f := range diff.Files {
+		s = append(s, summary{f.Path, f.OldPath, f.Status, f.Additions, f.Deletions, f.IsBinary, f.Kind, f.OldMode, f.NewMode, f.OldHash, f.NewHash})
+	}
+	return s
+}
+
+func TestBackendsAgree(t *testing.T) {
+	f := newFixture(t)
+	f.write("keep.go", "package keep\n\nfunc A() {}\n")
+	f.write("edit.go", "package edit\n\nvar x = 1\nvar y = 2\n")
+	f.write("gone.txt", "bye\n")
+	f.write("moved.txt", lines(20))
+	f.write("img.png", "\x89PNG\x00\x01")
+	f.commit("feat: init")
+	f.commit("fix: second\n\nwith a body")
+
+	f.write("edit.go", "package edit\n\nvar x = 10\nvar y = 2\nvar z = 3\n")
+	f.write("new file.txt", "hello\nworld\n")
+	f.write("img.png", "\x89PNG\x00\x01\x02")
+	f.git("rm", "-q", "gone.txt")
+	f.git("rm", "-q", "moved.txt")
+	f.write("dir/moved.txt", lines(20))
+	f.git("add", "-A")
+
+	goRepo, err := OpenGoRepository(f.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]Repository{"exec": f.exec(), "go": goRepo}
+
+	var want []summary
+	for _, name := range []string{"exec", "go"} {
+		diff, err := backends[name].StagedDiff()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got := summarize(diff)
+		if want == nil {
+			want = got
+			continue
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: %+v\nexec: %+v", name, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s file %d = %+v, exec = %+v", name, i, got[i], want[i])
+			}
+		}
+	}
+
+	for name, repo := range backends {
+		commits, err := repo.History(10)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(commits) != 2 || commits[0].Subject != "fix: second" || commits[0].Body != "with a body" {
+			t.Errorf("%s history = %+v", name, commits)
+		}
+		if branch, _ := repo.Branch(); branch != "main" {
+			t.Errorf("%s branch = %q", name, branch)
+		}
+		if data, err := repo.StagedFile("new file.txt"); err != nil || string(data) != "hello\nworld\n" {
+			t.Errorf("%s staged file = %q, %v", name, data, err)
+		}
+		if data, err := repo.HeadFile("edit.go"); err != nil || !bytes.Contains(data, []byte("var x = 1\n")) {
+			t.Errorf("%s HEAD file = %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestGoRepositoryInMemory(t *testing.T) {
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(path, content string) {
+		t.Helper()
+		file, err := wt.Filesystem.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(content))
+		file.Close()
+		if _, err := wt.Add(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("a.txt", "one\n")
+	sig := &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	if _, err := wt.Commit("feat: add a", &gogit.CommitOptions{Author: sig}); err != nil {
+		t.Fatal(err)
+	}
+	write("a.txt", "one\ntwo\n")
+	write("b.txt", "new\n")
+
+	r := NewGoRepository(repo)
+	diff, err := r.StagedDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := summarize(diff)
+	want := []summary{
+		{Path: "a.txt", Status: "modified", Additions: 1, OldMode: "100644", NewMode: "100644",
+			OldHash: "5626abf0f72e58d7a153368ba57db4c673c0e171", NewHash: "814f4a422927b82f5f8a43f8fab6d3839e3983f2"},
+		{Path: "b.txt", Status: "added", Additions: 1, NewMode: "100644", NewHash: "3e757656cf36eca53338e520d134963a44f793f8"},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("diff = %+v, want %+v", got, want)
+	}
+
+	commits, err := r.History(5)
+	if err != nil || len(commits) != 1 || commits[0].Subject != "feat: add a" {
+		t.Errorf("history = %+v, %v", commits, err)
+	}
+}
+
+func TestIndexTree(t *testing.T) {
+	f := newFixture(t)
+	f.write("a.txt", "a\n")
+	f.commit("init")
+	goRepo, err := OpenGoRepository(f.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := func() (string, string) {
+		t.Helper()
+		execKey, err := f.exec().IndexTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		goKey, err := goRepo.IndexTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if execKey != goKey {
+			t.Errorf("exec key %s, go key %s", execKey, goKey)
+		}
+		return execKey, goKey
+	}
+
+	before, _ := keys()
+	objects := f.git("count-objects", "-v")
+	if again, _ := keys(); again != before || f.git("count-objects", "-v") != objects {
+		t.Error("reading the index changed it or wrote objects")
+	}
+
+	f.write("a.txt", "b\n")
+	f.git("add", "a.txt")
+	if staged, _ := keys(); staged == before {
+		t.Error("key unchanged after staging")
+	}
+	for name, repo := range map[string]Repository{"exec": f.exec(), "go": goRepo} {
+		if files, err := repo.StagedFiles(); err != nil || len(files) != 1 || files[0] != "a.txt" {
+			t.Errorf("%s: staged files = %q, %v", name, files, err)
+		}
+	}
+
+	// A conflicted merge leaves an unmerged index that write-tree refuses
+	f.commit("b")
+	f.git("checkout", "-q", "-b", "side", "HEAD~1")
+	f.write("a.txt", "c\n")
+	f.commit("c")
+	cmd := exec.Command("git", "merge", "-q", "main")
+	cmd.Dir = f.dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+	if cmd.Run() == nil {
+		t.Fatal("merge did not conflict")
+	}
+	keys()
+}
@@ -1,7 +1,6 @@
 package git
 
 import (
-	"os/exec"
 	"strconv"
 	"strings"
 )
@@ -12,17 +11,28 @@ type DiffResult struct {
 	Stats    DiffStats
 	RawDiff  string
 	IsBinary bool
+
+	repo Repository
 }
 
 // FileChange represents changes to a single file
 type FileChange struct {
 	Path       string
-	OldPath    string // for renames
-	Status     string // added, modified, deleted, renamed
+	OldPath    string // for renames and copies
+	Status     string // added, modified, deleted, renamed, copied
+	Similarity int    // percentage, for renames and copies
 	Additions  int
 	Deletions  int
 	IsBinary   bool
 	DiffChunk  string
+	Kind       string // submodule, lfs, binary or empty
+	Note       string // description for files a diff can't show
+
+	// Octal modes and object hashes of both sides, empty for the side
+	// an added or deleted file lacks. Submodules have mode 160000 and the
+	// hash of their commit.
+	OldMode, NewMode string
+	OldHash, NewHash string
 }
 
 // DiffStats holds overall diff statistics
@@ -37,81 +47,129 @@ func (d *DiffResult) IsEmpty() bool {
 	return len(d.Files) == 0
 }
 
+// Repo returns the repository the diff was read from, nil for a parsed diff
+func (d *DiffResult) Repo() Repository {
+	return d.repo
+}
+
+// renameArgs enables rename and copy detection with git's default
+// 50% similarity threshold, so raw, numstat and the patch agree
+var renameArgs = []string{"--find-renames", "--find-copies"}
+
 // GetStagedDiff returns the diff of staged changes
 func GetStagedDiff() (*DiffResult, error) {
+	return Default().StagedDiff()
+}
+
+// StagedDiff returns the diff of staged changes
+func (r *ExecRepository) StagedDiff() (*DiffResult, error) {
 	// Get raw diff
-	cmd := exec.Command("git", "diff", "--cached", "--unified=3")
-	rawDiff, err := cmd.Output()
+	rawDiff, err := r.output(append([]string{"diff", "--cached", "--unified=3"}, renameArgs...)...)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get file stats
-	cmd = exec.Command("git", "diff", "--cached", "--numstat")
-	numstat, _ := cmd.Output()
+	// Get file status, modes and hashes
+	raw, err := r.output(append([]string{"diff", "--cached", "-z", "--raw", "--no-abbrev"}, renameArgs...)...)
+	if err != nil {
+		return nil, err
+	}
 
-	// Get file status
-	cmd = exec.Command("git", "diff", "--cached", "--name-status")
-	nameStatus, _ := cmd.Output()
+	// Get file stats
+	numstat, _ := r.output(append([]string{"diff", "--cached", "-z", "--numstat"}, renameArgs...)...)
 
 	result := &DiffResult{
 		RawDiff: string(rawDiff),
-		Files:   parseNameStatus(string(nameStatus)),
+		Files:   parseRaw(raw),
 	}
 
 	// Parse numstat and merge with files
-	parseNumstat(string(numstat), result)
+	parseNumstat(numstat, result)
+
+	assignChunks(result)
+	result.finish(r)
+
+	return result, nil
+}
 
-	// Calculate totals
-	for _, f := range result.Files {
-		result.Stats.Additions += f.Additions
-		result.Stats.Deletions += f.Deletions
+// finish describes special files and calculates totals
+func (d *DiffResult) finish(repo interface {
+	Repository
+	inspector
+}) {
+	d.repo = repo
+	describeSpecialFiles(repo, d)
+	d.total()
+}
+
+// total calculates overall statistics from the files
+func (d *DiffResult) total() {
+	d.Stats = DiffStats{}
+	for _, f := range d.Files {
+		d.Stats.Additions += f.Additions
+		d.Stats.Deletions += f.Deletions
 		if f.IsBinary {
-			result.IsBinary = true
+			d.IsBinary = true
 		}
 	}
-	result.Stats.FilesChanged = len(result.Files)
+	d.Stats.FilesChanged = len(d.Files)
+}
 
-	return result, nil
+// splitNUL splits -z output into fields, dropping the trailing terminator
+func splitNUL(out []byte) []string {
+	s := strings.TrimSuffix(string(out), "\x00")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\x00")
 }
 
-// parseNameStatus parses git diff --name-status output
-func parseNameStatus(output string) []FileChange {
+// parseRaw parses git diff -z --raw output. Entries are
+// ":oldmode newmode oldhash newhash X\0path\0", with "R085\0old\0new\0"
+// in place of "X\0path\0" for renames and copies.
+func parseRaw(output []byte) []FileChange {
 	var files []FileChange
-	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := splitNUL(output)
 
-	for _, line := range lines {
-		if line == "" {
+	for i := 0; i < len(fields); i++ {
+		meta := strings.Fields(strings.TrimPrefix(fields[i], ":"))
+		if len(meta) != 5 || i+1 >= len(fields) {
 			continue
 		}
+		code := meta[4]
 
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
-			continue
-		}
-
-		fc := FileChange{
-			Path: parts[1],
-		}
-
-		switch parts[0][0] {
+		fc := FileChange{}
+		switch code[0] {
 		case 'A':
 			fc.Status = "added"
-		case 'M':
-			fc.Status = "modified"
 		case 'D':
 			fc.Status = "deleted"
 		case 'R':
 			fc.Status = "renamed"
-			if len(parts) >= 3 {
-				fc.OldPath = parts[1]
-				fc.Path = parts[2]
-			}
 		case 'C':
 			fc.Status = "copied"
 		default:
 			fc.Status = "modified"
 		}
+		if meta[0] != nullMode {
+			fc.OldMode, fc.OldHash = meta[0], meta[2]
+		}
+		if meta[1] != nullMode {
+			fc.NewMode, fc.NewHash = meta[1], meta[3]
+		}
+
+		if code[0] == 'R' || code[0] == 'C' {
+			if i+2 >= len(fields) {
+				break
+			}
+			fc.Similarity, _ = strconv.Atoi(code[1:])
+			fc.OldPath = fields[i+1]
+			fc.Path = fields[i+2]
+			i += 2
+		} else {
+			fc.Path = fields[i+1]
+			i++
+		}
 
 		files = append(files, fc)
 	}
@@ -119,39 +177,35 @@ func parseNameStatus(output string) []FileChange {
 	return files
 }
 
-// parseNumstat parses git diff --numstat output and updates files
-func parseNumstat(output string, result *DiffResult) {
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
+// parseNumstat parses git diff -z --numstat output and updates files.
+// Entries are "add\tdel\tpath\0", or "add\tdel\t\0old\0new\0" for renames.
+func parseNumstat(output []byte, result *DiffResult) {
+	fields := splitNUL(output)
 
-		parts := strings.Fields(line)
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "\t", 3)
 		if len(parts) < 3 {
 			continue
 		}
 
 		path := parts[2]
-
-		// Handle renames (path is "old => new")
-		if strings.Contains(path, " => ") {
-			pathParts := strings.Split(path, " => ")
-			if len(pathParts) >= 2 {
-				path = pathParts[1]
+		if path == "" {
+			if i+2 >= len(fields) {
+				break
 			}
+			path = fields[i+2]
+			i += 2
 		}
 
 		// Find matching file
-		for i := range result.Files {
-			if result.Files[i].Path == path {
+		for j := range result.Files {
+			if result.Files[j].Path == path {
 				// Binary files show "-" for additions/deletions
 				if parts[0] == "-" {
-					result.Files[i].IsBinary = true
+					result.Files[j].IsBinary = true
 				} else {
-					result.Files[i].Additions, _ = strconv.Atoi(parts[0])
-					result.Files[i].Deletions, _ = strconv.Atoi(parts[1])
+					result.Files[j].Additions, _ = strconv.Atoi(parts[0])
+					result.Files[j].Deletions, _ = strconv.Atoi(parts[1])
 				}
 				break
 			}
@@ -159,6 +213,145 @@ func parseNumstat(output string, result *DiffResult) {
 	}
 }
 
+// assignChunks splits the patch into per-file chunks. git emits files in
+// the same order for the patch and raw output, so chunks are matched by
+// position rather than by parsing quoted paths out of the headers.
+func assignChunks(result *DiffResult) {
+	chunks := splitDiff(result.RawDiff)
+	if len(chunks) != len(result.Files) {
+		return
+	}
+	for i := range result.Files {
+		result.Files[i].DiffChunk = chunks[i]
+	}
+}
+
+// splitDiff splits a unified diff into per-file chunks
+func splitDiff(raw string) []string {
+	var chunks []string
+	var sb strings.Builder
+
+	for _, line := range strings.SplitAfter(raw, "\n") {
+		if strings.HasPrefix(line, "diff --git ") && sb.Len() > 0 {
+			chunks = append(chunks, sb.String())
+			sb.Reset()
+		}
+		sb.WriteString(line)
+	}
+	if sb.Len() > 0 {
+		chunks = append(chunks, sb.String())
+	}
+
+	return chunks
+}
+
+// ParseDiff parses a unified diff in git's format, such as the output of
+// git diff or git format-patch, without access to the repository
+func ParseDiff(raw string) *DiffResult {
+	result := &DiffResult{RawDiff: raw}
+	for _, chunk := range splitDiff(raw) {
+		if !strings.HasPrefix(chunk, "diff --git ") {
+			continue
+		}
+		result.Files = append(result.Files, parseChunk(chunk))
+	}
+	result.total()
+	return result
+}
+
+// parseChunk reads a file's status, paths and line counts from its patch
+func parseChunk(chunk string) FileChange {
+	fc := FileChange{Status: "modified", DiffChunk: chunk}
+	var oldPath, newPath string
+	inHunk := false
+
+	for _, line := range strings.Split(chunk, "\n") {
+		if inHunk {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				fc.Additions++
+			case strings.HasPrefix(line, "-"):
+				fc.Deletions++
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case strings.HasPrefix(line, "diff --git "):
+			// Only reliable for unquoted paths without " b/", which the
+			// ---, +++ and rename lines below override
+			if a, b, ok := strings.Cut(strings.TrimPrefix(line, "diff --git "), " b/"); ok {
+				oldPath, newPath = strings.TrimPrefix(a, "a/"), b
+			}
+		case strings.HasPrefix(line, "new file mode"):
+			fc.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode"):
+			fc.Status = "deleted"
+		case strings.HasPrefix(line, "rename from "):
+			fc.Status = "renamed"
+			oldPath = strings.TrimPrefix(line, "rename from ")
+		case strings.HasPrefix(line, "rename to "):
+			newPath = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "copy from "):
+			fc.Status = "copied"
+			oldPath = strings.TrimPrefix(line, "copy from ")
+		case strings.HasPrefix(line, "copy to "):
+			newPath = strings.TrimPrefix(line, "copy to ")
+		case strings.HasPrefix(line, "similarity index "):
+			fc.Similarity, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "similarity index "), "%"))
+		case strings.HasPrefix(line, "--- a/"):
+			oldPath = strings.TrimPrefix(line, "--- a/")
+		case strings.HasPrefix(line, "+++ b/"):
+			newPath = strings.TrimPrefix(line, "+++ b/")
+		case strings.HasPrefix(line, "Binary files "):
+			fc.IsBinary = true
+		}
+	}
+
+	fc.Path = newPath
+	if fc.Status == "deleted" {
+		fc.Path = oldPath
+	}
+	if fc.Status == "renamed" || fc.Status == "copied" {
+		fc.OldPath = oldPath
+	}
+	return fc
+}
+
+// StagedFile returns the staged (index) content of a file
+func (r *ExecRepository) StagedFile(path string) ([]byte, error) {
+	return r.output("show", ":"+path)
+}
+
+// HeadFile returns the content of a file at HEAD
+func (r *ExecRepository) HeadFile(path string) ([]byte, error) {
+	return r.output("show", "HEAD:"+path)
+}
+
+// Images returns the HEAD and staged contents of a changed file.
+// The missing side of an added or deleted file is nil, as are both
+// sides for a diff parsed from text.
+func (d *DiffResult) Images(f FileChange) (oldData, newData []byte) {
+	repo := d.repo
+	if repo == nil {
+		return nil, nil
+	}
+
+	oldPath := f.Path
+	if f.OldPath != "" {
+		oldPath = f.OldPath
+	}
+	if f.Status != "added" {
+		oldData, _ = repo.HeadFile(oldPath)
+	}
+	if f.Status != "deleted" {
+		newData, _ = repo.StagedFile(f.Path)
+	}
+	return oldData, newData
+}
+
 // Summary returns a brief summary of the diff
 func (d *DiffResult) Summary() string {
 	var sb strings.Builder
@@ -175,8 +368,19 @@ func (d *DiffResult) Summary() string {
 			sb.WriteString("[~] ")
 		case "renamed":
 			sb.WriteString("[R] ")
+		case "copied":
+			sb.WriteString("[C] ")
+		}
+		if f.OldPath != "" {
+			sb.WriteString(f.OldPath)
+			sb.WriteString(" -> ")
 		}
 		sb.WriteString(f.Path)
+		if f.Similarity > 0 && f.Similarity < 100 {
+			sb.WriteString(" [")
+			sb.WriteString(strconv.Itoa(f.Similarity))
+			sb.WriteString("% similar]")
+		}
 		if f.Additions > 0 || f.Deletions > 0 {
 			sb.WriteString(" (")
 			if f.Additions > 0 {
@@ -0,0 +1,256 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStagedDiffSpecialPaths(t *testing.T) {
+	f := newFixture(t)
+	f.write("README", "readme\n")
+	f.commit("init")
+
+	paths := []string{"with space.txt", "with\ttab.txt", "with\nnewline.txt", "dir/ümlaut.txt", `quote".txt`}
+	for _, p := range paths {
+		f.write(p, "one\ntwo\n")
+	}
+	f.git("add", "-A")
+
+	diff, err := f.exec().StagedDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Files) != len(paths) {
+		t.Fatalf("got %d files, want %d", len(diff.Files), len(paths))
+	}
+	for _, p := range paths {
+		fc := file(t, diff, p)
+		if fc.Status != "added" {
+			t.Errorf("%q: status %q, want added", p, fc.Status)
+		}
+		if fc.Additions != 2 || fc.Deletions != 0 {
+			t.Errorf("%q: +%d -%d, want +2 -0", p, fc.Additions, fc.Deletions)
+		}
+		if !strings.HasPrefix(fc.DiffChunk, "diff --git ") {
+			t.Errorf("%q: missing patch chunk", p)
+		}
+	}
+	if diff.Stats.FilesChanged != len(paths) || diff.Stats.Additions != 2*len(paths) {
+		t.Errorf("stats = %+v", diff.Stats)
+	}
+}
+
+func TestStagedDiffRenamesAndCopies(t *testing.T) {
+	f := newFixture(t)
+	content := lines(40)
+	f.write("old name.go", content)
+	f.write("orig.go", content+"// orig\n")
+	f.commit("init")
+
+	// A rename with one changed line, and a copy of a modified file
+	f.git("mv", "old name.go", "new\tname.go")
+	f.write("new\tname.go", strings.Replace(content, "line aa", "line zz", 1))
+	f.write("orig.go", content+"// changed\n")
+	f.write("copy.go", content+"// orig\n")
+	f.git("add", "-A")
+
+	diff, err := f.exec().StagedDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := file(t, diff, "new\tname.go")
+	if renamed.Status != "renamed" || renamed.OldPath != "old name.go" {
+		t.Errorf("rename = %q from %q", renamed.Status, renamed.OldPath)
+	}
+	if renamed.Similarity < 90 || renamed.Similarity == 100 {
+		t.Errorf("rename similarity = %d, want 90-99", renamed.Similarity)
+	}
+	if renamed.Additions != 1 || renamed.Deletions != 1 {
+		t.Errorf("rename +%d -%d, want +1 -1", renamed.Additions, renamed.Deletions)
+	}
+
+	copied := file(t, diff, "copy.go")
+	if copied.Status != "copied" || copied.OldPath != "orig.go" || copied.Similarity != 100 {
+		t.Errorf("copy = %q from %q (%d%%)", copied.Status, copied.OldPath, copied.Similarity)
+	}
+
+	if modified := file(t, diff, "orig.go"); modified.Status != "modified" {
+		t.Errorf("orig.go status = %q", modified.Status)
+	}
+}
+
+func TestStagedDiffBinary(t *testing.T) {
+	f := newFixture(t)
+	f.write("img.png", "\x89PNG\x00\x01\x02")
+	f.commit("init")
+
+	f.write("img.png", "\x89PNG\x00\x01\x02\x03\x04")
+	f.write("blob.bin", "\x00\x00\x00")
+	f.git("add", "-A")
+
+	diff, err := f.exec().StagedDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.IsBinary {
+		t.Error("diff not marked binary")
+	}
+	for _, p := range []string{"img.png", "blob.bin"} {
+		fc := file(t, diff, p)
+		if !fc.IsBinary || fc.Additions != 0 || fc.Deletions != 0 {
+			t.Errorf("%s: binary=%t +%d -%d", p, fc.IsBinary, fc.Additions, fc.Deletions)
+		}
+		if fc.Kind != "binary" || fc.Note == "" {
+			t.Errorf("%s: kind %q, note %q", p, fc.Kind, fc.Note)
+		}
+	}
+	if note := file(t, diff, "img.png").Note; !strings.Contains(note, "image/png") || !strings.Contains(note, "(+2 B)") {
+		t.Errorf("img.png note = %q", note)
+	}
+}
+
+func TestStagedDiffSubmodule(t *testing.T) {
+	f := newFixture(t)
+	sub := func(args ...string) string {
+		t.Helper()
+		return f.git(append([]string{"-C", "sub"}, args...)...)
+	}
+	f.write("sub/README", "sub\n")
+	sub("init", "-q", "-b", "main")
+	sub("add", "-A")
+	sub("-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "sub: init")
+	f.write("main.txt", "main\n")
+	f.git("add", "-A")
+
+	diff, err := f.exec().StagedDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fc := file(t, diff, "sub"); fc.Kind != KindSubmodule || !strings.HasPrefix(fc.Note, "added submodule sub at ") {
+		t.Errorf("added: kind %q, note %q", fc.Kind, fc.Note)
+	}
+	// A file mentioning gitlinks in its content is not a submodule
+	f.write("notes.txt", "Subproject commit 1234567\n")
+	f.git("add", "-A")
+	f.commit("init")
+
+	for _, subject := range []string{"fix: one | two", "feat: three"} {
+		sub("-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", subject)
+	}
+	f.write("notes.txt", "Subproject commit 7654321\n")
+	f.git("add", "-A")
+
+	for name, diff := range bothDiffs(t, f) {
+		note := file(t, diff, "sub").Note
+		if !strings.Contains(note, "2 new commit(s): feat: three; fix: one | two") || strings.Contains(note, "rolled back") {
+			t.Errorf("%s: update note = %q", name, note)
+		}
+		if fc := file(t, diff, "notes.txt"); fc.Kind != "" {
+			t.Errorf("%s: notes.txt kind = %q", name, fc.Kind)
+		}
+	}
+	f.commit("update sub")
+
+	sub("checkout", "-q", "HEAD~2")
+	f.git("add", "sub")
+	for name, diff := range bothDiffs(t, f) {
+		note := file(t, diff, "sub").Note
+		if !strings.Contains(note, "2 commit(s) rolled back: feat: three; fix: one | two") || strings.Contains(note, "new commit") {
+			t.Errorf("%s: rollback note = %q", name, note)
+		}
+	}
+}
+
+// bothDiffs reads the staged diff of f with the exec and go backends
+func bothDiffs(t *testing.T, f *fixture) map[string]*DiffResult {
+	t.Helper()
+	goRepo, err := OpenGoRepository(f.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs := map[string]*DiffResult{}
+	for name, repo := range map[string]Repository{"exec": f.exec(), "go": goRepo} {
+		if diffs[name], err = repo.StagedDiff(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	return diffs
+}
+
+func TestParseRaw(t *testing.T) {
+	const (
+		a = "1111111111111111111111111111111111111111"
+		b = "2222222222222222222222222222222222222222"
+		z = "0000000000000000000000000000000000000000"
+	)
+	out := []byte(":100644 100644 " + a + " " + b + " M\x00a b\x00" +
+		":100644 100644 " + a + " " + b + " R087\x00old\x00new\tname\x00" +
+		":100644 100644 " + a + " " + a + " C100\x00src\x00dst\x00" +
+		":100644 000000 " + a + " " + z + " D\x00gone\nfile\x00" +
+		":000000 160000 " + z + " " + b + " A\x00sub\x00")
+	got := parseRaw(out)
+	want := []FileChange{
+		{Path: "a b", Status: "modified", OldMode: "100644", NewMode: "100644", OldHash: a, NewHash: b},
+		{Path: "new\tname", OldPath: "old", Status: "renamed", Similarity: 87, OldMode: "100644", NewMode: "100644", OldHash: a, NewHash: b},
+		{Path: "dst", OldPath: "src", Status: "copied", Similarity: 100, OldMode: "100644", NewMode: "100644", OldHash: a, NewHash: a},
+		{Path: "gone\nfile", Status: "deleted", OldMode: "100644", OldHash: a},
+		{Path: "sub", Status: "added", NewMode: "160000", NewHash: b},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d files, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("file %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseNumstat(t *testing.T) {
+	result := &DiffResult{Files: []FileChange{{Path: "a\tb"}, {Path: "new"}, {Path: "bin"}}}
+	parseNumstat([]byte("3\t1\ta\tb\x005\t2\t\x00old\x00new\x00-\t-\tbin\x00"), result)
+
+	if f := result.Files[0]; f.Additions != 3 || f.Deletions != 1 {
+		t.Errorf("a\\tb = +%d -%d", f.Additions, f.Deletions)
+	}
+	if f := result.Files[1]; f.Additions != 5 || f.Deletions != 2 {
+		t.Errorf("rename = +%d -%d", f.Additions, f.Deletions)
+	}
+	if !result.Files[2].IsBinary {
+		t.Error("bin not binary")
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	raw := `diff --git a/x.go b/x.go
+index 1111111..2222222 100644
+--- a/x.go
++++ b/x.go
+@@ -1,2 +1,2 @@
+ package x
+-var a = 1
++var a = 2
+diff --git a/old.go b/new.go
+similarity index 90%
+rename from old.go
+rename to new.go
+diff --git a/img.png b/img.png
+new file mode 100644
+index 0000000..3333333
+Binary files /dev/null and b/img.png differ
+`
+	diff := ParseDiff(raw)
+	if len(diff.Files) != 3 {
+		t.Fatalf("got %d files", len(diff.Files))
+	}
+	if f := diff.Files[0]; f.Path != "x.go" || f.Additions != 1 || f.Deletions != 1 {
+		t.Errorf("x.go = %+v", f)
+	}
+	if f := diff.Files[1]; f.Status != "renamed" || f.OldPath != "old.go" || f.Path != "new.go" || f.Similarity != 90 {
+		t.Errorf("rename = %+v", f)
+	}
+	if f := diff.Files[2]; f.Status != "added" || !f.IsBinary {
+		t.Errorf("img.png = %+v", f)
+	}
+}
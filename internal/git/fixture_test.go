@@ -0,0 +1,86 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// fixture is a throwaway repository in a temp directory
+type fixture struct {
+	t   *testing.T
+	dir string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	f := &fixture{t: t, dir: t.TempDir()}
+	f.git("init", "-q", "-b", "main")
+	f.git("config", "user.name", "Test")
+	f.git("config", "user.email", "test@example.com")
+	f.git("config", "commit.gpgsign", "false")
+	return f
+}
+
+func (f *fixture) git(args ...string) string {
+	f.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = f.dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		f.t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func (f *fixture) write(path, content string) {
+	f.t.Helper()
+	full := filepath.Join(f.dir, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		f.t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fixture) commit(message string) {
+	f.t.Helper()
+	f.git("add", "-A")
+	f.git("commit", "-q", "--allow-empty", "-m", message)
+}
+
+func (f *fixture) exec() *ExecRepository {
+	return &ExecRepository{Dir: f.dir}
+}
+
+// file returns the change for path, failing the test if there is none
+func file(t *testing.T, diff *DiffResult, path string) FileChange {
+	t.Helper()
+	for _, fc := range diff.Files {
+		if fc.Path == path {
+			return fc
+		}
+	}
+	var paths []string
+	for _, fc := range diff.Files {
+		paths = append(paths, fc.Path)
+	}
+	t.Fatalf("no change for %q in %q", path, paths)
+	return FileChange{}
+}
+
+// lines returns n distinct lines, enough for rename detection
+func lines(n int) string {
+	var s string
+	for i := 0; i < n; i++ {
+		s += "line " + string(rune('a'+i%26)) + string(rune('a'+i/26)) + "\n"
+	}
+	return s
+}
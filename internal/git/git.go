@@ -2,41 +2,50 @@ package git
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os/exec"
 	"strings"
 )
 
-// IsRepo checks if current directory is a git repository
-func IsRepo() bool {
-	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
-	err := cmd.Run()
-	return err == nil
+// ExecRepository runs the git binary in Dir, or the current directory
+// when Dir is empty
+type ExecRepository struct {
+	Dir string
 }
 
-// GetRootDir returns the root directory of the git repository
-func GetRootDir() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	out, err := cmd.Output()
+func (r *ExecRepository) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	return cmd
+}
+
+func (r *ExecRepository) output(args ...string) ([]byte, error) {
+	return r.command(args...).Output()
+}
+
+// Root returns the root directory of the git repository
+func (r *ExecRepository) Root() (string, error) {
+	out, err := r.output("rev-parse", "--show-toplevel")
 	if err != nil {
 		return "", fmt.Errorf("not a git repository")
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
-// GetCurrentBranch returns the current branch name
-func GetCurrentBranch() (string, error) {
-	cmd := exec.Command("git", "branch", "--show-current")
-	out, err := cmd.Output()
+// Branch returns the current branch name
+func (r *ExecRepository) Branch() (string, error) {
+	out, err := r.output("branch", "--show-current")
 	if err != nil {
 		return "", err
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
-// CreateCommit creates a commit with the given message
-func CreateCommit(message string) error {
-	cmd := exec.Command("git", "commit", "-m", message)
+// Commit creates a commit with the given message
+func (r *ExecRepository) Commit(message string) error {
+	cmd := r.command("commit", "-m", message)
 	cmd.Stdin = nil
 
 	var stderr bytes.Buffer
@@ -48,24 +57,70 @@ func CreateCommit(message string) error {
 	return nil
 }
 
-// HasStagedChanges checks if there are staged changes
+// IsRepo checks if current directory is a git repository
+func IsRepo() bool {
+	_, err := Default().Root()
+	return err == nil
+}
+
+// GetRootDir returns the root directory of the git repository
+func GetRootDir() (string, error) {
+	return Default().Root()
+}
+
+// GetCurrentBranch returns the current branch name
+func GetCurrentBranch() (string, error) {
+	return Default().Branch()
+}
+
+// CreateCommit creates a commit with the given message
+func CreateCommit(message string) error {
+	return Default().Commit(message)
+}
+
+// HasStagedChanges checks if the default repository has staged changes
 func HasStagedChanges() bool {
-	cmd := exec.Command("git", "diff", "--cached", "--quiet")
-	err := cmd.Run()
-	return err != nil // exit code 1 means there are changes
+	diff, err := Default().StagedDiff()
+	return err == nil && !diff.IsEmpty()
+}
+
+// GetIndexTree returns the hash of the default repository's index, which
+// identifies the staged content
+func GetIndexTree() (string, error) {
+	return Default().IndexTree()
 }
 
 // GetStagedFiles returns list of staged files
 func GetStagedFiles() ([]string, error) {
-	cmd := exec.Command("git", "diff", "--cached", "--name-only")
-	out, err := cmd.Output()
+	return Default().StagedFiles()
+}
+
+// IndexTree hashes the index as git ls-files --stage lists it
+func (r *ExecRepository) IndexTree() (string, error) {
+	out, err := r.output("ls-files", "--stage", "-z")
+	if err != nil {
+		return "", fmt.Errorf("failed to read index: %w", err)
+	}
+	return hashIndex(out), nil
+}
+
+// StagedFiles returns the paths with staged changes
+func (r *ExecRepository) StagedFiles() ([]string, error) {
+	out, err := r.output("diff", "--cached", "-z", "--name-only")
 	if err != nil {
 		return nil, err
 	}
 
-	files := strings.Split(strings.TrimSpace(string(out)), "\n")
-	if len(files) == 1 && files[0] == "" {
+	files := splitNUL(out)
+	if files == nil {
 		return []string{}, nil
 	}
 	return files, nil
 }
+
+// hashIndex hashes index entries listed as "mode hash stage\tpath\0", the
+// format of git ls-files --stage -z, so both backends agree
+func hashIndex(entries []byte) string {
+	sum := sha256.Sum256(entries)
+	return hex.EncodeToString(sum[:])
+}
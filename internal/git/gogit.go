@@ -0,0 +1,551 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// maxSubmoduleWalk bounds the commits read when listing submodule updates
+const maxSubmoduleWalk = 1000
+
+// GoRepository reads and commits to a repository with go-git, so no git
+// binary is needed. Renames are detected only for identical content and
+// hooks are not run on commit.
+type GoRepository struct {
+	repo *gogit.Repository
+}
+
+// OpenGoRepository opens the repository containing dir
+func OpenGoRepository(dir string) (*GoRepository, error) {
+	repo, err := gogit.PlainOpenWithOptions(dir, &gogit.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("not a git repository")
+	}
+	return &GoRepository{repo: repo}, nil
+}
+
+// NewGoRepository wraps an opened go-git repository, such as one with
+// in-memory storage
+func NewGoRepository(repo *gogit.Repository) *GoRepository {
+	return &GoRepository{repo: repo}
+}
+
+// Root returns the root directory of the working tree
+func (r *GoRepository) Root() (string, error) {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to open worktree: %w", err)
+	}
+	return wt.Filesystem.Root(), nil
+}
+
+// Branch returns the current branch name, including an unborn one
+func (r *GoRepository) Branch() (string, error) {
+	ref, err := r.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+	if ref.Type() != plumbing.SymbolicReference {
+		return "", nil
+	}
+	return ref.Target().Short(), nil
+}
+
+// Commit creates a commit from the index using the configured identity
+func (r *GoRepository) Commit(message string) error {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+	if _, err := wt.Commit(message, &gogit.CommitOptions{}); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+	return nil
+}
+
+// History returns the last n non-merge commits
+func (r *GoRepository) History(n int) ([]Commit, error) {
+	if n <= 0 {
+		n = 10
+	}
+
+	iter, err := r.repo.Log(&gogit.LogOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var commits []Commit
+	err = iter.ForEach(func(c *object.Commit) error {
+		if c.NumParents() > 1 {
+			return nil
+		}
+		subject, body, _ := strings.Cut(c.Message, "\n")
+		commits = append(commits, Commit{
+			Hash:    short(c.Hash.String()),
+			Author:  c.Author.Name,
+			Subject: strings.TrimSpace(subject),
+			Body:    strings.TrimSpace(body),
+		})
+		if len(commits) >= n {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return commits, err
+}
+
+// StagedFile returns the content of a file in the index
+func (r *GoRepository) StagedFile(path string) ([]byte, error) {
+	e, err := r.indexEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.blob(e.hash)
+}
+
+// HeadFile returns the content of a file at HEAD
+func (r *GoRepository) HeadFile(path string) ([]byte, error) {
+	e, err := r.headEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.blob(e.hash)
+}
+
+// StagedDiff compares the HEAD tree with the index and renders each
+// change as a unified diff in git's format
+func (r *GoRepository) StagedDiff() (*DiffResult, error) {
+	oldEntries, err := r.headEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	newEntries, err := r.indexEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	files := compareEntries(oldEntries, newEntries)
+
+	result := &DiffResult{}
+	var raw strings.Builder
+	for i := range files {
+		setObjects(&files[i], oldEntries, newEntries)
+		if err := r.fillPatch(&files[i], oldEntries, newEntries); err != nil {
+			return nil, err
+		}
+		raw.WriteString(files[i].DiffChunk)
+	}
+	result.Files = files
+	result.RawDiff = raw.String()
+	result.finish(r)
+
+	return result, nil
+}
+
+// IndexTree hashes the index entries, stages included, in the format of
+// git ls-files --stage -z
+func (r *GoRepository) IndexTree() (string, error) {
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return "", fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var b bytes.Buffer
+	for _, e := range idx.Entries {
+		fmt.Fprintf(&b, "%06o %s %d\t%s\x00", uint32(e.Mode), e.Hash, e.Stage, e.Name)
+	}
+	return hashIndex(b.Bytes()), nil
+}
+
+// StagedFiles returns the paths with staged changes
+func (r *GoRepository) StagedFiles() ([]string, error) {
+	diff, err := r.StagedDiff()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(diff.Files))
+	for _, f := range diff.Files {
+		files = append(files, f.Path)
+	}
+	return files, nil
+}
+
+// treeEntry is a blob or submodule in a tree or the index
+type treeEntry struct {
+	hash plumbing.Hash
+	mode filemode.FileMode
+}
+
+func (r *GoRepository) headTree() (*object.Tree, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	commit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// headEntries lists the files at HEAD, which is empty before the first commit
+func (r *GoRepository) headEntries() (map[string]treeEntry, error) {
+	entries := map[string]treeEntry{}
+
+	tree, err := r.headTree()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, e, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Mode == filemode.Dir {
+			continue
+		}
+		entries[name] = treeEntry{hash: e.Hash, mode: e.Mode}
+	}
+	return entries, nil
+}
+
+// indexEntries lists merged files in the index
+func (r *GoRepository) indexEntries() (map[string]treeEntry, error) {
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]treeEntry{}
+	for _, e := range idx.Entries {
+		if e.Stage != 0 || e.IntentToAdd {
+			continue
+		}
+		entries[e.Name] = treeEntry{hash: e.Hash, mode: e.Mode}
+	}
+	return entries, nil
+}
+
+func (r *GoRepository) headEntry(path string) (treeEntry, error) {
+	tree, err := r.headTree()
+	if err != nil {
+		return treeEntry{}, err
+	}
+	e, err := tree.FindEntry(path)
+	if err != nil {
+		return treeEntry{}, err
+	}
+	return treeEntry{hash: e.Hash, mode: e.Mode}, nil
+}
+
+func (r *GoRepository) indexEntry(path string) (treeEntry, error) {
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return treeEntry{}, err
+	}
+	e, err := idx.Entry(path)
+	if err != nil {
+		return treeEntry{}, err
+	}
+	return treeEntry{hash: e.Hash, mode: e.Mode}, nil
+}
+
+func (r *GoRepository) blob(hash plumbing.Hash) ([]byte, error) {
+	b, err := r.repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	rd, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return io.ReadAll(rd)
+}
+
+// content returns an entry as diff text; submodules diff as their commit
+func (r *GoRepository) content(e treeEntry) ([]byte, error) {
+	if e.mode == filemode.Submodule {
+		return []byte("Subproject commit " + e.hash.String() + "\n"), nil
+	}
+	return r.blob(e.hash)
+}
+
+// setObjects records the modes and hashes of both sides of f, as git
+// diff --raw reports them
+func setObjects(f *FileChange, oldEntries, newEntries map[string]treeEntry) {
+	oldPath := f.Path
+	if f.OldPath != "" {
+		oldPath = f.OldPath
+	}
+	if e, ok := oldEntries[oldPath]; ok && f.Status != "added" {
+		f.OldMode, f.OldHash = fmt.Sprintf("%06o", uint32(e.mode)), e.hash.String()
+	}
+	if e, ok := newEntries[f.Path]; ok && f.Status != "deleted" {
+		f.NewMode, f.NewHash = fmt.Sprintf("%06o", uint32(e.mode)), e.hash.String()
+	}
+}
+
+// compareEntries lists changed paths in order, pairing deleted and added
+// files with identical content as renames
+func compareEntries(oldEntries, newEntries map[string]treeEntry) []FileChange {
+	paths := make([]string, 0, len(oldEntries)+len(newEntries))
+	for p := range oldEntries {
+		paths = append(paths, p)
+	}
+	for p := range newEntries {
+		if _, ok := oldEntries[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var files []FileChange
+	deleted := map[plumbing.Hash][]string{}
+	for _, p := range paths {
+		o, inOld := oldEntries[p]
+		n, inNew := newEntries[p]
+		switch {
+		case inOld && inNew:
+			if o != n {
+				files = append(files, FileChange{Path: p, Status: "modified"})
+			}
+		case inNew:
+			files = append(files, FileChange{Path: p, Status: "added"})
+		default:
+			files = append(files, FileChange{Path: p, Status: "deleted"})
+			deleted[o.hash] = append(deleted[o.hash], p)
+		}
+	}
+
+	renamed := map[string]bool{}
+	for i := range files {
+		f := &files[i]
+		if f.Status != "added" || newEntries[f.Path].mode == filemode.Submodule {
+			continue
+		}
+		candidates := deleted[newEntries[f.Path].hash]
+		if len(candidates) == 0 {
+			continue
+		}
+		f.Status = "renamed"
+		f.OldPath = candidates[0]
+		f.Similarity = 100
+		renamed[candidates[0]] = true
+		deleted[newEntries[f.Path].hash] = candidates[1:]
+	}
+
+	kept := files[:0]
+	for _, f := range files {
+		if f.Status == "deleted" && renamed[f.Path] {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// fillPatch renders the diff chunk and line counts of a file
+func (r *GoRepository) fillPatch(f *FileChange, oldEntries, newEntries map[string]treeEntry) error {
+	oldPath := f.Path
+	if f.OldPath != "" {
+		oldPath = f.OldPath
+	}
+
+	fp := &filePatch{}
+	var oldData, newData []byte
+	if f.Status != "added" {
+		e := oldEntries[oldPath]
+		fp.from = &patchFile{path: oldPath, treeEntry: e}
+	}
+	if f.Status != "deleted" {
+		e := newEntries[f.Path]
+		fp.to = &patchFile{path: f.Path, treeEntry: e}
+	}
+
+	if fp.from == nil || fp.to == nil || fp.from.hash != fp.to.hash {
+		var err error
+		if fp.from != nil {
+			if oldData, err = r.content(fp.from.treeEntry); err != nil {
+				return fmt.Errorf("failed to read %s: %w", oldPath, err)
+			}
+		}
+		if fp.to != nil {
+			if newData, err = r.content(fp.to.treeEntry); err != nil {
+				return fmt.Errorf("failed to read %s: %w", f.Path, err)
+			}
+		}
+	}
+
+	if isBinaryData(oldData) || isBinaryData(newData) {
+		fp.binary = true
+		f.IsBinary = true
+	} else {
+		for _, d := range diff.Do(string(oldData), string(newData)) {
+			c := &patchChunk{content: d.Text}
+			switch d.Type {
+			case diffmatchpatch.DiffInsert:
+				c.op = fdiff.Add
+				f.Additions += countLines(d.Text)
+			case diffmatchpatch.DiffDelete:
+				c.op = fdiff.Delete
+				f.Deletions += countLines(d.Text)
+			default:
+				c.op = fdiff.Equal
+			}
+			fp.chunks = append(fp.chunks, c)
+		}
+	}
+
+	var sb strings.Builder
+	if err := fdiff.NewUnifiedEncoder(&sb, fdiff.DefaultContextLines).Encode(patch{fp}); err != nil {
+		return fmt.Errorf("failed to render diff: %w", err)
+	}
+	f.DiffChunk = sb.String()
+	return nil
+}
+
+// isBinaryData uses git's rule: a NUL byte in the first 8000 bytes
+func isBinaryData(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
+
+func (r *GoRepository) blobSize(path string, staged bool) (int64, error) {
+	var e treeEntry
+	var err error
+	if staged {
+		e, err = r.indexEntry(path)
+	} else {
+		e, err = r.headEntry(path)
+	}
+	if err != nil {
+		return 0, err
+	}
+	b, err := r.repo.BlobObject(e.hash)
+	if err != nil {
+		return 0, err
+	}
+	return b.Size, nil
+}
+
+// submoduleLog returns the subjects of commits in from..to of a checked
+// out submodule. It walks back from to and gives nothing when from is
+// not an ancestor, as for a rollback.
+func (r *GoRepository) submoduleLog(path, from, to string) ([]string, error) {
+	root, err := r.Root()
+	if err != nil {
+		return nil, err
+	}
+	sub, err := gogit.PlainOpen(filepath.Join(root, path))
+	if err != nil {
+		return nil, err
+	}
+	iter, err := sub.Log(&gogit.LogOptions{From: plumbing.NewHash(to)})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var subjects []string
+	found, walked := false, 0
+	err = iter.ForEach(func(c *object.Commit) error {
+		if c.Hash.String() == from {
+			found = true
+			return storer.ErrStop
+		}
+		if walked++; walked > maxSubmoduleWalk {
+			return storer.ErrStop
+		}
+		if c.NumParents() <= 1 {
+			subject, _, _ := strings.Cut(c.Message, "\n")
+			subjects = append(subjects, strings.TrimSpace(subject))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return subjects, nil
+}
+
+// patch, filePatch, patchFile and patchChunk adapt a staged change to
+// go-git's unified diff encoder
+type patch []fdiff.FilePatch
+
+func (p patch) FilePatches() []fdiff.FilePatch { return p }
+func (p patch) Message() string                { return "" }
+
+type filePatch struct {
+	from, to *patchFile
+	chunks   []fdiff.Chunk
+	binary   bool
+}
+
+func (p *filePatch) IsBinary() bool        { return p.binary }
+func (p *filePatch) Chunks() []fdiff.Chunk { return p.chunks }
+
+func (p *filePatch) Files() (from, to fdiff.File) {
+	// A nil *patchFile must become a nil interface for the encoder
+	if p.from != nil {
+		from = p.from
+	}
+	if p.to != nil {
+		to = p.to
+	}
+	return from, to
+}
+
+type patchFile struct {
+	treeEntry
+	path string
+}
+
+func (f *patchFile) Hash() plumbing.Hash     { return f.hash }
+func (f *patchFile) Mode() filemode.FileMode { return f.mode }
+func (f *patchFile) Path() string            { return f.path }
+
+type patchChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c *patchChunk) Content() string       { return c.content }
+func (c *patchChunk) Type() fdiff.Operation { return c.op }
@@ -1,7 +1,6 @@
 package git
 
 import (
-	"os/exec"
 	"strconv"
 	"strings"
 )
@@ -14,37 +13,36 @@ type Commit struct {
 	Author  string
 }
 
+// GetCommitHistory returns the last n non-merge commits
 func GetCommitHistory(n int) ([]Commit, error) {
+	return Default().History(n)
+}
+
+// History returns the last n non-merge commits. Fields are NUL-separated
+// so subjects and bodies may contain any text.
+func (r *ExecRepository) History(n int) ([]Commit, error) {
 	if n <= 0 {
 		n = 10
 	}
 
-	format := "%h|%s|%b|%an"
-	cmd := exec.Command("git", "log", "-n", strconv.Itoa(n), "--format="+format, "--no-merges")
-
-	out, err := cmd.Output()
+	// With -z each commit is also NUL-terminated, so the output is a flat
+	// list of fields in groups of four. The body goes last since it is
+	// the only field that may be empty or span lines.
+	format := "%h%x00%an%x00%s%x00%b"
+	out, err := r.output("log", "-z", "-n", strconv.Itoa(n), "--format="+format, "--no-merges")
 	if err != nil {
 		return nil, err
 	}
 
 	var commits []Commit
-	entries := strings.Split(strings.TrimSpace(string(out)), "\n")
-
-	for _, entry := range entries {
-		if entry == "" {
-			continue
-		}
-
-		parts := strings.SplitN(entry, "|", 4)
-		if len(parts) < 4 {
-			continue
-		}
+	fields := splitNUL(out)
 
+	for i := 0; i+3 < len(fields); i += 4 {
 		commits = append(commits, Commit{
-			Hash:    parts[0],
-			Subject: parts[1],
-			Body:    parts[2],
-			Author:  parts[3],
+			Hash:    strings.TrimSpace(fields[i]),
+			Author:  fields[i+1],
+			Subject: fields[i+2],
+			Body:    strings.TrimSpace(fields[i+3]),
 		})
 	}
 
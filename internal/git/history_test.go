@@ -0,0 +1,65 @@
+package git
+
+import "testing"
+
+func TestHistory(t *testing.T) {
+	f := newFixture(t)
+	f.commit("first")
+	f.commit("feat: a | b || c\n\nbody with | pipes\nand\x01lines")
+	f.commit("fix: subject\nwrapped onto a second line")
+	f.git("checkout", "-q", "-b", "side")
+	f.commit("side")
+	f.git("checkout", "-q", "main")
+	f.commit("main")
+	f.git("merge", "-q", "--no-ff", "-m", "Merge side", "side")
+
+	commits, err := f.exec().History(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ subject, body string }{
+		{"main", ""},
+		{"side", ""},
+		// git joins a subject paragraph spread over lines with spaces
+		{"fix: subject wrapped onto a second line", ""},
+		{"feat: a | b || c", "body with | pipes\nand\x01lines"},
+		{"first", ""},
+	}
+	if len(commits) != len(want) {
+		t.Fatalf("got %d commits, want %d: %+v", len(commits), len(want), commits)
+	}
+	for i, w := range want {
+		c := commits[i]
+		if c.Subject != w.subject || c.Body != w.body {
+			t.Errorf("commit %d = %q / %q, want %q / %q", i, c.Subject, c.Body, w.subject, w.body)
+		}
+		if c.Author != "Test" || len(c.Hash) < 7 {
+			t.Errorf("commit %d: author %q, hash %q", i, c.Author, c.Hash)
+		}
+	}
+
+	if commits, _ := f.exec().History(2); len(commits) != 2 {
+		t.Errorf("History(2) returned %d commits", len(commits))
+	}
+}
+
+func TestDefaultRepository(t *testing.T) {
+	f := newFixture(t)
+	f.commit("first")
+	repo, err := OpenGoRepository(f.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetDefault(repo)
+	t.Cleanup(func() { SetDefault(nil) })
+
+	if HasStagedChanges() {
+		t.Error("staged changes in a clean repository")
+	}
+	f.write("a.txt", "a\n")
+	f.git("add", "a.txt")
+	if !HasStagedChanges() {
+		t.Error("no staged changes after git add")
+	}
+}
@@ -0,0 +1,103 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// HookDir is the directory git runs hooks from
+type HookDir struct {
+	Path      string // absolute
+	HooksPath bool   // set by core.hooksPath rather than the default
+}
+
+// GetHookDir resolves the hooks directory of the current repository. It
+// follows linked worktrees, where .git is a file, and core.hooksPath.
+func GetHookDir() (HookDir, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return HookDir{}, fmt.Errorf("not a git repository")
+	}
+
+	path, err := filepath.Abs(strings.TrimSpace(string(out)))
+	if err != nil {
+		return HookDir{}, fmt.Errorf("failed to resolve hooks directory: %w", err)
+	}
+
+	hooksPath, _ := exec.Command("git", "config", "core.hooksPath").Output()
+	return HookDir{
+		Path:      path,
+		HooksPath: strings.TrimSpace(string(hooksPath)) != "",
+	}, nil
+}
+
+// Source names the setting that selected the directory
+func (h HookDir) Source() string {
+	if h.HooksPath {
+		return "core.hooksPath"
+	}
+	return "default"
+}
+
+// AutoCommentChars are the characters git tries, in order, when
+// core.commentChar is auto
+const AutoCommentChars = "#;@!$%^&|:"
+
+// UnusedCommentChar returns the first of AutoCommentChars that starts no
+// line of text, as git picks for auto
+func UnusedCommentChar(text string) string {
+	for _, c := range AutoCommentChars {
+		used := false
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, string(c)) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			return string(c)
+		}
+	}
+	return "#"
+}
+
+// CommentChar returns what git starts comment lines of commit messages
+// with in the repository at dir, from core.commentChar. It is empty for
+// "auto", where git picks a character per message.
+func CommentChar(dir string) string {
+	cmd := exec.Command("git", "config", "--get", "core.commentChar")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	value := strings.TrimSpace(string(out))
+	switch {
+	case err != nil || value == "":
+		return "#"
+	case value == "auto":
+		return ""
+	}
+	return value
+}
+
+// GetGlobalConfig returns a value from the user's global git config
+func GetGlobalConfig(key string) string {
+	out, _ := exec.Command("git", "config", "--global", "--get", key).Output()
+	return strings.TrimSpace(string(out))
+}
+
+// SetGlobalConfig sets a value in the user's global git config
+func SetGlobalConfig(key, value string) error {
+	if out, err := exec.Command("git", "config", "--global", key, value).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set %s: %s", key, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// UnsetGlobalConfig removes a value from the user's global git config
+func UnsetGlobalConfig(key string) error {
+	if out, err := exec.Command("git", "config", "--global", "--unset", key).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to unset %s: %s", key, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package git
+
+import "testing"
+
+func TestUnusedCommentChar(t *testing.T) {
+	tests := map[string]string{
+		"fix: parser\n\nbody":                  "#",
+		"fix: parser\n\n#1 handled first":      ";",
+		"#1\n;2\n@3\n!4\n$5\n%6\n^7\n&8\n|9\n": ":",
+	}
+	for text, want := range tests {
+		if got := UnusedCommentChar(text); got != want {
+			t.Errorf("UnusedCommentChar(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestCommentChar(t *testing.T) {
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	f := newFixture(t)
+	if got := CommentChar(f.dir); got != "#" {
+		t.Errorf("default = %q", got)
+	}
+	f.git("config", "core.commentChar", ";")
+	if got := CommentChar(f.dir); got != ";" {
+		t.Errorf("core.commentChar ; = %q", got)
+	}
+	f.git("config", "core.commentChar", "auto")
+	if got := CommentChar(f.dir); got != "" {
+		t.Errorf("core.commentChar auto = %q", got)
+	}
+}
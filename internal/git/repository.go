@@ -0,0 +1,94 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Repository is a git repository that autocommit reads changes from and
+// commits to
+type Repository interface {
+	// Root returns the top-level directory of the working tree
+	Root() (string, error)
+	// Branch returns the current branch name, empty when HEAD is detached
+	Branch() (string, error)
+	// StagedDiff returns the changes staged in the index
+	StagedDiff() (*DiffResult, error)
+	// History returns the last n non-merge commits, newest first
+	History(n int) ([]Commit, error)
+	// Commit creates a commit from the index
+	Commit(message string) error
+	// StagedFile returns the content of a file in the index
+	StagedFile(path string) ([]byte, error)
+	// HeadFile returns the content of a file at HEAD
+	HeadFile(path string) ([]byte, error)
+	// IndexTree returns a hash of the index that changes whenever the
+	// staged content does. Unlike git write-tree it writes no objects and
+	// works on an unmerged index.
+	IndexTree() (string, error)
+	// StagedFiles lists the paths with staged changes
+	StagedFiles() ([]string, error)
+}
+
+// inspector is implemented by backends to describe submodule and binary
+// changes without loading whole blobs
+type inspector interface {
+	blobSize(path string, staged bool) (int64, error)
+	submoduleLog(path, from, to string) ([]string, error)
+}
+
+// Backends
+const (
+	BackendExec = "exec"
+	BackendGo   = "go"
+)
+
+// Open opens the repository containing dir. The exec backend runs the git
+// binary, the go backend reads the repository directly. An empty backend
+// uses the git binary when it is installed.
+func Open(dir, backend string) (Repository, error) {
+	if backend == "" {
+		backend = BackendExec
+		if _, err := exec.LookPath("git"); err != nil {
+			backend = BackendGo
+		}
+	}
+
+	switch backend {
+	case BackendExec:
+		r := &ExecRepository{Dir: dir}
+		if _, err := r.Root(); err != nil {
+			return nil, err
+		}
+		return r, nil
+	case BackendGo:
+		r, err := OpenGoRepository(dir)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unknown git backend: %s (use exec or go)", backend)
+	}
+}
+
+var defaultRepo Repository
+
+// Default returns the repository used by the package-level functions,
+// which is the one containing the current directory unless SetDefault
+// was called
+func Default() Repository {
+	if defaultRepo != nil {
+		return defaultRepo
+	}
+	if r, err := Open(".", ""); err == nil {
+		defaultRepo = r
+		return r
+	}
+	return &ExecRepository{}
+}
+
+// SetDefault sets the repository used by the package-level functions
+func SetDefault(r Repository) {
+	defaultRepo = r
+}
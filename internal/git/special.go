@@ -0,0 +1,230 @@
+package git
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Special file kinds
+const (
+	KindSubmodule = "submodule"
+	KindLFS       = "lfs"
+	KindBinary    = "binary"
+)
+
+const lfsSpec = "git-lfs.github.com/spec/v1"
+
+// Modes in raw diffs: a gitlink is a submodule's commit, the null mode
+// the missing side of an added or deleted file
+const (
+	gitlinkMode = "160000"
+	nullMode    = "000000"
+)
+
+// maxSubmoduleSubjects limits how many submodule commits are listed
+const maxSubmoduleSubjects = 5
+
+// Notes returns natural-language descriptions of submodule, LFS and
+// binary changes, which a raw diff shows only as hashes or "Binary files differ"
+func (d *DiffResult) Notes() []string {
+	var notes []string
+	for _, f := range d.Files {
+		if f.Note != "" {
+			notes = append(notes, f.Note)
+		}
+	}
+	return notes
+}
+
+// describeSpecialFiles fills Kind and Note for files that need more than a diff
+func describeSpecialFiles(repo inspector, result *DiffResult) {
+	for i := range result.Files {
+		f := &result.Files[i]
+		switch {
+		case f.OldMode == gitlinkMode || f.NewMode == gitlinkMode:
+			f.Kind = KindSubmodule
+			f.Note = describeSubmodule(repo, f)
+		case strings.Contains(f.DiffChunk, lfsSpec):
+			f.Kind = KindLFS
+			f.Note = describeLFS(result, f)
+		case f.IsBinary:
+			f.Kind = KindBinary
+			f.Note = describeBinary(repo, f)
+		}
+	}
+}
+
+func describeSubmodule(repo inspector, f *FileChange) string {
+	var oldCommit, newCommit string
+	if f.OldMode == gitlinkMode {
+		oldCommit = f.OldHash
+	}
+	if f.NewMode == gitlinkMode {
+		newCommit = f.NewHash
+	}
+
+	switch {
+	case oldCommit == "":
+		return fmt.Sprintf("added submodule %s at %s", f.Path, short(newCommit))
+	case newCommit == "":
+		return fmt.Sprintf("removed submodule %s (was at %s)", f.Path, short(oldCommit))
+	}
+
+	note := fmt.Sprintf("submodule %s updated from %s to %s", f.Path, short(oldCommit), short(newCommit))
+
+	added, err := repo.submoduleLog(f.Path, oldCommit, newCommit)
+	if err != nil {
+		return note
+	}
+	// A rollback or a rewritten history also drops commits
+	removed, err := repo.submoduleLog(f.Path, newCommit, oldCommit)
+	if err != nil {
+		return note
+	}
+	if len(added) > 0 {
+		note += fmt.Sprintf(", %d new commit(s): %s", len(added), listSubjects(added))
+	}
+	if len(removed) > 0 {
+		note += fmt.Sprintf(", %d commit(s) rolled back: %s", len(removed), listSubjects(removed))
+	}
+	return note
+}
+
+// listSubjects joins the first few subjects of submodule commits
+func listSubjects(subjects []string) string {
+	shown := subjects
+	if len(shown) > maxSubmoduleSubjects {
+		shown = shown[:maxSubmoduleSubjects]
+	}
+	list := strings.Join(shown, "; ")
+	if len(subjects) > len(shown) {
+		list += "; ..."
+	}
+	return list
+}
+
+func describeLFS(d *DiffResult, f *FileChange) string {
+	oldData, newData := d.Images(*f)
+	oldSize, oldOK := lfsPointerSize(oldData)
+	newSize, newOK := lfsPointerSize(newData)
+	kind := fileType(f.Path)
+
+	switch {
+	case !oldOK && newOK:
+		return fmt.Sprintf("added LFS %s %s (%s)", kind, f.Path, humanSize(newSize))
+	case oldOK && !newOK && f.Status == "deleted":
+		return fmt.Sprintf("removed LFS %s %s (%s)", kind, f.Path, humanSize(oldSize))
+	case oldOK && newOK:
+		return fmt.Sprintf("updated LFS %s %s: %s -> %s (%s)",
+			kind, f.Path, humanSize(oldSize), humanSize(newSize), sizeDelta(oldSize, newSize))
+	}
+	return fmt.Sprintf("changed LFS pointer %s", f.Path)
+}
+
+// lfsPointerSize reads the size line from a Git LFS pointer file
+func lfsPointerSize(data []byte) (int64, bool) {
+	if !strings.Contains(string(data), lfsSpec) {
+		return 0, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "size "); ok {
+			size, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return size, err == nil
+		}
+	}
+	return 0, false
+}
+
+func describeBinary(repo inspector, f *FileChange) string {
+	kind := fileType(f.Path)
+	oldPath := f.Path
+	if f.OldPath != "" {
+		oldPath = f.OldPath
+	}
+
+	switch f.Status {
+	case "added":
+		size, _ := repo.blobSize(f.Path, true)
+		return fmt.Sprintf("added binary %s %s (%s)", kind, f.Path, humanSize(size))
+	case "deleted":
+		size, _ := repo.blobSize(f.Path, false)
+		return fmt.Sprintf("removed binary %s %s (%s)", kind, f.Path, humanSize(size))
+	}
+
+	oldSize, err1 := repo.blobSize(oldPath, false)
+	newSize, err2 := repo.blobSize(f.Path, true)
+	if err1 != nil || err2 != nil {
+		return fmt.Sprintf("changed binary %s %s", kind, f.Path)
+	}
+	return fmt.Sprintf("changed binary %s %s: %s -> %s (%s)",
+		kind, f.Path, humanSize(oldSize), humanSize(newSize), sizeDelta(oldSize, newSize))
+}
+
+func (r *ExecRepository) blobSize(path string, staged bool) (int64, error) {
+	object := "HEAD:" + path
+	if staged {
+		object = ":" + path
+	}
+	out, err := r.output("cat-file", "-s", object)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// submoduleLog returns the subjects of commits in from..to of a submodule
+func (r *ExecRepository) submoduleLog(path, from, to string) ([]string, error) {
+	root, err := r.Root()
+	if err != nil {
+		return nil, err
+	}
+	out, err := r.output("-C", filepath.Join(root, path), "log", "-z", "--no-merges", "--format=%s", from+".."+to)
+	if err != nil {
+		return nil, err
+	}
+	return splitNUL(out), nil
+}
+
+// fileType names the kind of file from its extension, e.g. "image/png file"
+func fileType(p string) string {
+	ext := strings.ToLower(path.Ext(p))
+	if ext == "" {
+		return "file"
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		t, _, _ = strings.Cut(t, ";")
+		return t + " file"
+	}
+	return strings.TrimPrefix(ext, ".") + " file"
+}
+
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func sizeDelta(oldSize, newSize int64) string {
+	if newSize >= oldSize {
+		return "+" + humanSize(newSize-oldSize)
+	}
+	return "-" + humanSize(oldSize-newSize)
+}
+
+func short(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,179 @@
+package heuristic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/josinSbazin/AutoCommit/internal/deps"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+)
+
+// Analysis is what can be learned from a diff without an LLM
+type Analysis struct {
+	Added   []string
+	Removed []string
+	Changed []string
+	Deps    []deps.Change
+}
+
+// symbolPatterns find function and class definitions in non-Go sources
+var symbolPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\*?\s+([A-Za-z_$][\w$]*)`),
+	regexp.MustCompile(`^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^\s*(?:export\s+)?(?:abstract\s+)?class\s+([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?fn\s+([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^\s*(?:public|private|protected|internal)\s+(?:static\s+)?(?:[\w<>\[\],]+\s+)+([A-Za-z_]\w*)\s*\(`),
+}
+
+// Analyze inspects staged files for added, removed and changed symbols
+// and dependency changes
+func Analyze(diff *git.DiffResult) Analysis {
+	a := Analysis{Deps: deps.Analyze(diff)}
+
+	for _, f := range diff.Files {
+		if f.IsBinary {
+			continue
+		}
+
+		switch {
+		case deps.IsManifest(f.Path):
+			// already covered by deps.Analyze
+		case path.Ext(f.Path) == ".go":
+			oldData, newData := diff.Images(f)
+			added, removed, changed := goSymbols(oldData, newData)
+			a.Added = append(a.Added, added...)
+			a.Removed = append(a.Removed, removed...)
+			a.Changed = append(a.Changed, changed...)
+		default:
+			added, removed, changed := diffSymbols(f.DiffChunk)
+			a.Added = append(a.Added, added...)
+			a.Removed = append(a.Removed, removed...)
+			a.Changed = append(a.Changed, changed...)
+		}
+	}
+
+	return a
+}
+
+// goSymbols compares top-level declarations of two Go sources
+func goSymbols(oldData, newData []byte) (added, removed, changed []string) {
+	oldDecls := goDecls(oldData)
+	newDecls := goDecls(newData)
+	return compare(oldDecls, newDecls)
+}
+
+// goDecls maps top-level funcs, methods and types to their source text
+func goDecls(data []byte) map[string]string {
+	decls := make(map[string]string)
+	if len(data) == 0 {
+		return decls
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", data, parser.SkipObjectResolution)
+	if err != nil {
+		return decls
+	}
+
+	text := func(n ast.Node) string {
+		return string(data[fset.Position(n.Pos()).Offset:fset.Position(n.End()).Offset])
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name = receiverName(d.Recv.List[0].Type) + "." + name
+			}
+			decls[name] = text(d)
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				decls[ts.Name.Name] = text(ts)
+			}
+		}
+	}
+	return decls
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// diffSymbols finds definitions on added and removed lines of a diff chunk.
+// A symbol on both sides is considered changed.
+func diffSymbols(chunk string) (added, removed, changed []string) {
+	plus := make(map[string]string)
+	minus := make(map[string]string)
+
+	for _, line := range strings.Split(chunk, "\n") {
+		if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+			continue
+		}
+		var target map[string]string
+		switch {
+		case strings.HasPrefix(line, "+"):
+			target = plus
+		case strings.HasPrefix(line, "-"):
+			target = minus
+		default:
+			continue
+		}
+		for _, re := range symbolPatterns {
+			if m := re.FindStringSubmatch(line[1:]); m != nil {
+				target[m[1]] = line[1:]
+				break
+			}
+		}
+	}
+
+	added, removed, _ = compare(minus, plus)
+	for name := range plus {
+		if _, ok := minus[name]; ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return added, removed, changed
+}
+
+// compare returns keys only in newer, only in older, and in both with different values
+func compare(older, newer map[string]string) (added, removed, changed []string) {
+	for name, body := range newer {
+		old, ok := older[name]
+		switch {
+		case !ok:
+			added = append(added, name)
+		case old != body:
+			changed = append(changed, name)
+		}
+	}
+	for name := range older {
+		if _, ok := newer[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
@@ -0,0 +1,352 @@
+package heuristic
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/deps"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+)
+
+// genericDirs are skipped when inferring a scope from paths
+var genericDirs = map[string]bool{
+	"internal": true, "pkg": true, "src": true, "lib": true,
+	"cmd": true, "app": true, "apps": true, "packages": true,
+}
+
+// Generate drafts a commit message from the diff without calling an LLM.
+// The result is deterministic for a given diff and config.
+func Generate(cfg *config.Config, diff *git.DiffResult) string {
+	a := Analyze(diff)
+	files := diff.Files
+
+	typ := GuessType(files)
+	scope := GuessScope(files)
+	description := Describe(files)
+	var body []string
+
+	switch {
+	case len(a.Deps) > 0 && deps.OnlyDependencies(files):
+		typ, scope = cfg.Conventional.DepsType, "deps"
+		if typ == "" {
+			typ = "build"
+		}
+		description = describeDeps(a.Deps)
+		for _, c := range a.Deps {
+			body = append(body, c.Describe())
+		}
+		if len(a.Deps) == 1 {
+			body = nil
+		}
+
+	case allStatus(files, "renamed"):
+		typ = "refactor"
+		body = FileList(files)
+
+	case len(a.Added)+len(a.Removed)+len(a.Changed) > 0:
+		switch {
+		case typ == "test" || typ == "docs":
+		case len(a.Added) > 0:
+			typ = "feat"
+		case len(a.Removed) > 0:
+			typ = "refactor"
+		}
+		description = describeSymbols(a)
+		body = symbolLines(a)
+		if len(files) > 1 {
+			body = append(body, FileList(files)...)
+		}
+
+	default:
+		body = FileList(files)
+		if len(files) == 1 {
+			body = nil
+		}
+	}
+
+	if len(cfg.Conventional.AllowedTypes) > 0 && !slices.Contains(cfg.Conventional.AllowedTypes, typ) {
+		typ = "chore"
+	}
+	if len(cfg.Conventional.AllowedScopes) > 0 && !slices.Contains(cfg.Conventional.AllowedScopes, scope) {
+		scope = ""
+	}
+
+	return Format(cfg, typ, scope, description, body)
+}
+
+func describeDeps(changes []deps.Change) string {
+	if len(changes) == 1 {
+		return changes[0].Describe()
+	}
+	for _, c := range changes {
+		if c.Kind != deps.Bumped {
+			return "update dependencies"
+		}
+	}
+	return fmt.Sprintf("bump %d dependencies", len(changes))
+}
+
+func describeSymbols(a Analysis) string {
+	switch {
+	case len(a.Added) > 0:
+		return "add " + joinNames(a.Added)
+	case len(a.Removed) > 0:
+		return "remove " + joinNames(a.Removed)
+	default:
+		return "update " + joinNames(a.Changed)
+	}
+}
+
+func symbolLines(a Analysis) []string {
+	var lines []string
+	for _, name := range a.Added {
+		lines = append(lines, "add "+name)
+	}
+	for _, name := range a.Removed {
+		lines = append(lines, "remove "+name)
+	}
+	for _, name := range a.Changed {
+		lines = append(lines, "update "+name)
+	}
+	return lines
+}
+
+// joinNames lists up to three names, e.g. "a, b and 2 more"
+func joinNames(names []string) string {
+	switch {
+	case len(names) == 1:
+		return names[0]
+	case len(names) <= 3:
+		return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+	default:
+		return fmt.Sprintf("%s, %s and %d more", names[0], names[1], len(names)-2)
+	}
+}
+
+// capitalize upper-cases the first letter of s, which may take several bytes
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// Format assembles a message in the configured style. Body lines are
+// written as bullets and omitted when the config disables the body.
+func Format(cfg *config.Config, typ, scope, description string, body []string) string {
+	var subject string
+	switch cfg.Style {
+	case "conventional":
+		subject = typ
+		if scope != "" {
+			subject += "(" + scope + ")"
+		}
+		subject += ": " + description
+	default:
+		subject = capitalize(description)
+	}
+
+	// Lengths are in characters, as message.Validate counts them
+	if runes := []rune(subject); cfg.MaxSubjectLength > 3 && len(runes) > cfg.MaxSubjectLength {
+		subject = strings.TrimSpace(string(runes[:cfg.MaxSubjectLength-3])) + "..."
+	}
+
+	if !cfg.IncludeBody || len(body) == 0 {
+		return subject
+	}
+
+	var sb strings.Builder
+	sb.WriteString(subject)
+	sb.WriteString("\n\n")
+	for _, line := range body {
+		sb.WriteString("- " + line + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// GuessType infers a Conventional Commits type from the changed paths
+func GuessType(files []git.FileChange) string {
+	if len(files) == 0 {
+		return "chore"
+	}
+
+	kinds := map[string]int{}
+	added := 0
+	for _, f := range files {
+		kinds[Classify(f.Path)]++
+		if f.Status == "added" {
+			added++
+		}
+	}
+
+	if len(kinds) == 1 {
+		for kind := range kinds {
+			switch kind {
+			case "code":
+			case "config":
+				return "chore"
+			default:
+				return kind
+			}
+		}
+	}
+
+	// Tests and docs alongside code don't change the nature of the commit
+	if kinds["code"] > 0 {
+		for _, f := range files {
+			if f.Status == "added" && Classify(f.Path) == "code" {
+				return "feat"
+			}
+		}
+		if allStatus(files, "renamed") {
+			return "refactor"
+		}
+		return "chore"
+	}
+
+	if added == len(files) {
+		return "feat"
+	}
+	return "chore"
+}
+
+// Classify sorts a path into test, docs, ci, build, config or code
+func Classify(p string) string {
+	lower := strings.ToLower(p)
+	base := path.Base(lower)
+	ext := path.Ext(base)
+
+	switch {
+	case strings.HasSuffix(base, "_test.go"),
+		strings.Contains(base, ".test."), strings.Contains(base, ".spec."),
+		strings.HasPrefix(base, "test_") && ext == ".py",
+		hasDir(lower, "test", "tests", "__tests__", "testdata", "spec"):
+		return "test"
+	case ext == ".md", ext == ".rst", ext == ".adoc",
+		strings.HasPrefix(base, "license"), strings.HasPrefix(base, "changelog"),
+		hasDir(lower, "docs", "doc"):
+		return "docs"
+	case strings.HasPrefix(lower, ".github/workflows/"), strings.HasPrefix(lower, ".circleci/"),
+		base == ".gitlab-ci.yml", base == ".travis.yml", base == "jenkinsfile",
+		base == "azure-pipelines.yml":
+		return "ci"
+	case base == "makefile", base == "dockerfile", base == "go.mod", base == "go.sum",
+		base == "package.json", base == "package-lock.json", base == "yarn.lock",
+		base == "pnpm-lock.yaml", base == "cargo.toml", base == "cargo.lock",
+		base == "pom.xml", base == "build.gradle", base == "requirements.txt",
+		base == "pyproject.toml", base == ".goreleaser.yml", base == ".goreleaser.yaml":
+		return "build"
+	case ext == ".yml", ext == ".yaml", ext == ".toml", ext == ".ini", ext == ".cfg",
+		ext == ".conf", ext == ".env", ext == ".properties", ext == ".json",
+		strings.HasPrefix(base, ".") && ext == base:
+		return "config"
+	}
+	return "code"
+}
+
+// GuessScope returns the common directory of the changed files, skipping
+// generic top-level directories like internal/ or src/
+func GuessScope(files []git.FileChange) string {
+	scope := ""
+	for i, f := range files {
+		s := scopeOf(f.Path)
+		if i == 0 {
+			scope = s
+		} else if s != scope {
+			return ""
+		}
+	}
+	return scope
+}
+
+func scopeOf(p string) string {
+	dirs := strings.Split(path.Dir(p), "/")
+	for _, d := range dirs {
+		if d == "." || d == "" || strings.HasPrefix(d, ".") || genericDirs[d] {
+			continue
+		}
+		return strings.ToLower(d)
+	}
+	return ""
+}
+
+// Describe builds the subject description from file statuses
+func Describe(files []git.FileChange) string {
+	if len(files) == 0 {
+		return "update files"
+	}
+
+	if len(files) == 1 {
+		f := files[0]
+		if f.Status == "renamed" && f.OldPath != "" {
+			return fmt.Sprintf("rename %s to %s", path.Base(f.OldPath), path.Base(f.Path))
+		}
+		return verb(f.Status) + " " + path.Base(f.Path)
+	}
+
+	v := "update"
+	if allStatus(files, files[0].Status) {
+		v = verb(files[0].Status)
+	}
+
+	if len(files) == 2 {
+		return fmt.Sprintf("%s %s and %s", v, path.Base(files[0].Path), path.Base(files[1].Path))
+	}
+	return fmt.Sprintf("%s %d files", v, len(files))
+}
+
+// FileList describes each file change as a body line
+func FileList(files []git.FileChange) []string {
+	var lines []string
+	for _, f := range files {
+		if f.Note != "" {
+			lines = append(lines, f.Note)
+			continue
+		}
+		if f.OldPath != "" {
+			lines = append(lines, fmt.Sprintf("%s %s to %s", verb(f.Status), f.OldPath, f.Path))
+			continue
+		}
+		lines = append(lines, verb(f.Status)+" "+f.Path)
+	}
+	return lines
+}
+
+func verb(status string) string {
+	switch status {
+	case "added":
+		return "add"
+	case "deleted":
+		return "remove"
+	case "renamed":
+		return "rename"
+	case "copied":
+		return "copy"
+	default:
+		return "update"
+	}
+}
+
+func allStatus(files []git.FileChange, status string) bool {
+	for _, f := range files {
+		if f.Status != status {
+			return false
+		}
+	}
+	return true
+}
+
+func hasDir(p string, names ...string) bool {
+	for _, d := range strings.Split(path.Dir(p), "/") {
+		if slices.Contains(names, d) {
+			return true
+		}
+	}
+	return false
+}
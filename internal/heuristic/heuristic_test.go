@@ -0,0 +1,30 @@
+package heuristic
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+)
+
+func TestFormatMultibyteSubject(t *testing.T) {
+	cfg := config.Default()
+	cfg.Style = "simple"
+	cfg.MaxSubjectLength = 20
+
+	tests := map[string]string{
+		"обновить парсер":                    "Обновить парсер",
+		"ändere die Konfiguration der Hooks": "Ändere die Konfig...",
+		"добавить поддержку длинных строк":   "Добавить поддержк...",
+		"": "",
+	}
+	for description, want := range tests {
+		got := Format(cfg, "", "", description, nil)
+		if got != want {
+			t.Errorf("Format(%q) = %q, want %q", description, got, want)
+		}
+		if !utf8.ValidString(got) || utf8.RuneCountInString(got) > cfg.MaxSubjectLength {
+			t.Errorf("Format(%q) = %q: invalid or too long", description, got)
+		}
+	}
+}
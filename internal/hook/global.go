@@ -0,0 +1,91 @@
+package hook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	beginGlobalMarker = "# >>> autocommit global >>>"
+	endGlobalMarker   = "# <<< autocommit global <<<"
+)
+
+// delegatedHooks are the client-side hooks a global core.hooksPath would
+// hide. Each gets a script that runs the repository's own hook, if any.
+// reference-transaction and post-index-change are left out since git runs
+// them constantly once a script exists.
+var delegatedHooks = []string{
+	"applypatch-msg", "pre-applypatch", "post-applypatch",
+	"pre-commit", "pre-merge-commit", Name, "commit-msg", "post-commit",
+	"pre-rebase", "post-checkout", "post-merge", "pre-push",
+	"post-rewrite", "pre-auto-gc", "push-to-checkout", "sendemail-validate",
+}
+
+// delegation runs the hook of the same name in the repository, using the
+// common dir so linked worktrees find it too
+const delegation = `local_hook="$(git rev-parse --git-common-dir)/hooks/$(basename "$0")"
+if [ -x "$local_hook" ]; then
+    exec "$local_hook" "$@"
+fi
+`
+
+func globalScript(name string) string {
+	var sb strings.Builder
+	sb.WriteString("#!/bin/sh\n")
+	sb.WriteString(beginGlobalMarker + "\n")
+	sb.WriteString("# Managed by autocommit. A global core.hooksPath hides .git/hooks,\n")
+	sb.WriteString("# so this runs the repository's own hook as well.\n")
+	if name == Name {
+		sb.WriteString(RunCommand + ` "$@" || exit $?` + "\n")
+	}
+	sb.WriteString(delegation)
+	sb.WriteString(endGlobalMarker + "\n")
+	return sb.String()
+}
+
+// InstallGlobal writes the hooks for a global core.hooksPath into dir
+func InstallGlobal(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range delegatedHooks {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(globalScript(name)), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InstallTemplate writes the hook into a git template directory, which
+// git copies into repositories on init and clone
+func InstallTemplate(dir string) (string, error) {
+	return Install(filepath.Join(dir, "hooks"), ModeBlock)
+}
+
+// IsGlobal reports whether dir holds our global hooks
+func IsGlobal(dir string) bool {
+	content, err := os.ReadFile(filepath.Join(dir, Name))
+	return err == nil && strings.Contains(string(content), beginGlobalMarker)
+}
+
+// UninstallGlobal removes the scripts InstallGlobal wrote, and dir if
+// nothing else is left in it
+func UninstallGlobal(dir string) error {
+	if !IsGlobal(dir) {
+		return fmt.Errorf("no autocommit global hooks in %s", dir)
+	}
+	for _, name := range delegatedHooks {
+		path := filepath.Join(dir, name)
+		content, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(content), beginGlobalMarker) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	os.Remove(dir)
+	return nil
+}
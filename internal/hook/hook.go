@@ -0,0 +1,416 @@
+package hook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Name is the git hook autocommit installs
+const Name = "prepare-commit-msg"
+
+// Install modes. Block inserts a marker-delimited block into the hook
+// script; chain turns the hook into a runner for a prepare-commit-msg.d
+// directory, so hooks in other languages keep working.
+const (
+	ModeBlock = "block"
+	ModeChain = "chain"
+)
+
+const (
+	beginMarker = "# >>> autocommit >>>"
+	endMarker   = "# <<< autocommit <<<"
+
+	beginChainMarker = "# >>> autocommit chain >>>"
+	endChainMarker   = "# <<< autocommit chain <<<"
+
+	backupSuffix = ".autocommit-backup"
+
+	// Scripts in the .d directory run in name order: ours first, so the
+	// previous hook can still edit the generated message
+	chainScript    = "10-autocommit"
+	previousScript = "20-previous"
+)
+
+const block = beginMarker + `
+# Managed by autocommit. Remove with 'autocommit hook uninstall'.
+autocommit_prepare_commit_msg() {
+    # Keep messages from -m, -F, templates, merges, squashes and amends
+    [ -n "$2" ] && return 0
+    if [ -s "$1" ]; then
+        first_line=$(head -n1 "$1")
+        if [ -n "$first_line" ] && ! echo "$first_line" | grep -q "^#"; then
+            return 0
+        fi
+    fi
+    autocommit generate --hook-mode --output "$1"
+}
+autocommit_prepare_commit_msg "$@" || exit $?
+` + endMarker + "\n"
+
+// legacyScript is the hook autocommit wrote before managed blocks. Only
+// an exact copy is replaced; a hook that merely calls autocommit is the
+// user's and gets the block and a backup like any other.
+const legacyScript = `#!/bin/sh
+COMMIT_MSG_FILE=$1
+COMMIT_SOURCE=$2
+
+if [ -n "$COMMIT_SOURCE" ]; then
+    exit 0
+fi
+
+if [ -s "$COMMIT_MSG_FILE" ]; then
+    first_line=$(head -n1 "$COMMIT_MSG_FILE")
+    if [ -n "$first_line" ] && ! echo "$first_line" | grep -q "^#"; then
+        exit 0
+    fi
+fi
+
+autocommit generate --hook-mode --output "$COMMIT_MSG_FILE"
+`
+
+const runner = "#!/bin/sh\n" + beginChainMarker + `
+# Managed by autocommit. Runs each executable in ` + Name + `.d in order.
+for hook in "$(dirname "$0")/` + Name + `.d"/*; do
+    [ -x "$hook" ] || continue
+    "$hook" "$@" || exit $?
+done
+` + endChainMarker + "\n"
+
+// State of the hook in a hooks directory
+type State int
+
+const (
+	NotInstalled State = iota
+	Installed          // a hook with our block, or a legacy autocommit hook
+	Chained            // our runner with our script in the .d directory
+	Foreign            // a hook without our block
+)
+
+func (s State) String() string {
+	switch s {
+	case Installed:
+		return "installed"
+	case Chained:
+		return "chained"
+	case Foreign:
+		return "not managed by autocommit"
+	default:
+		return "not installed"
+	}
+}
+
+// Status reports the state of the hook in dir
+func Status(dir string) State {
+	content, err := os.ReadFile(filepath.Join(dir, Name))
+	if err != nil {
+		return NotInstalled
+	}
+
+	switch {
+	case isRunner(string(content)):
+		if _, err := os.Stat(filepath.Join(chainDir(dir), chainScript)); err == nil {
+			return Chained
+		}
+		return Foreign
+	case hasBlock(string(content)), isLegacy(string(content)):
+		return Installed
+	default:
+		return Foreign
+	}
+}
+
+// BackupPath returns where the previous hook is saved on install
+func BackupPath(dir string) string {
+	return filepath.Join(dir, Name+backupSuffix)
+}
+
+// Install adds autocommit to the hook in dir and returns the path of the
+// script that holds our block. An existing hook is backed up first.
+func Install(dir, mode string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	hookPath := filepath.Join(dir, Name)
+
+	content, err := os.ReadFile(hookPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	exists := err == nil
+
+	// Once a hook is chained, keep chaining
+	chain := mode == ModeChain || isRunner(string(content))
+	switch {
+	case !chain && mode != "" && mode != ModeBlock:
+		return "", fmt.Errorf("unknown hook mode: %s (use block or chain)", mode)
+	case !chain && exists && !isShellScript(string(content)):
+		return "", fmt.Errorf("existing hook at %s is not a shell script. Use --chain to run it alongside autocommit", hookPath)
+	}
+
+	if exists && !isRunner(string(content)) && !hasBlock(string(content)) && !isLegacy(string(content)) {
+		if err := backup(hookPath, content); err != nil {
+			return "", err
+		}
+	}
+
+	if chain {
+		return installChain(dir, content, exists)
+	}
+
+	switch {
+	case !exists, isLegacy(string(content)):
+		content = []byte("#!/bin/sh\n" + block)
+	case hasBlock(string(content)):
+		content = []byte(replaceBlock(string(content), block))
+	default:
+		content = []byte(insertBlock(string(content)))
+	}
+
+	if err := os.WriteFile(hookPath, content, 0755); err != nil {
+		return "", err
+	}
+	return hookPath, nil
+}
+
+// installChain turns the hook into a runner for the .d directory, moving
+// whatever was there before next to our script
+func installChain(dir string, content []byte, exists bool) (string, error) {
+	hookPath := filepath.Join(dir, Name)
+	chain := chainDir(dir)
+	if err := os.MkdirAll(chain, 0755); err != nil {
+		return "", err
+	}
+
+	if exists && !isRunner(string(content)) {
+		previous := string(content)
+		if hasBlock(previous) {
+			previous = replaceBlock(previous, "")
+		}
+		if isLegacy(previous) {
+			previous = ""
+		}
+		if !isEmptyScript(previous) {
+			if err := os.WriteFile(filepath.Join(chain, previousScript), []byte(previous), 0755); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	script := filepath.Join(chain, chainScript)
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"+block), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(hookPath, []byte(runner), 0755); err != nil {
+		return "", err
+	}
+	return script, nil
+}
+
+// Uninstall removes only what autocommit added to the hook in dir. With
+// restore, the hook saved at install time is put back instead.
+func Uninstall(dir string, restore bool) error {
+	hookPath := filepath.Join(dir, Name)
+
+	if restore {
+		saved, err := os.ReadFile(BackupPath(dir))
+		if err != nil {
+			return fmt.Errorf("no backup to restore at %s", BackupPath(dir))
+		}
+		// Restoring replaces the runner, which would silently stop them
+		if others := foreignScripts(chainDir(dir)); len(others) > 0 {
+			return fmt.Errorf("%s also runs %s. Remove them first, or uninstall without --restore",
+				chainDir(dir), strings.Join(others, ", "))
+		}
+		if err := removeChain(dir); err != nil {
+			return err
+		}
+		if err := os.WriteFile(hookPath, saved, 0755); err != nil {
+			return err
+		}
+		return os.Remove(BackupPath(dir))
+	}
+
+	content, err := os.ReadFile(hookPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no hook installed at %s", hookPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case isRunner(string(content)):
+		err = unchain(dir)
+	case isLegacy(string(content)):
+		err = os.Remove(hookPath)
+	case hasBlock(string(content)):
+		rest := replaceBlock(string(content), "")
+		if isEmptyScript(rest) {
+			err = os.Remove(hookPath)
+		} else {
+			err = os.WriteFile(hookPath, []byte(rest), 0755)
+		}
+	default:
+		return fmt.Errorf("hook at %s has no autocommit block", hookPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	// What is left is the user's hook now; an older backup would only be
+	// restored over it by mistake later
+	if err := os.Remove(BackupPath(dir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// unchain removes our script from the .d directory. When only the
+// previous hook is left it moves back into place.
+func unchain(dir string) error {
+	hookPath := filepath.Join(dir, Name)
+	chain := chainDir(dir)
+
+	if err := os.Remove(filepath.Join(chain, chainScript)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	rest := chainScripts(chain)
+	switch {
+	case len(rest) == 0:
+		os.Remove(chain)
+		return os.Remove(hookPath)
+	case len(rest) == 1 && rest[0] == previousScript:
+		if err := os.Rename(filepath.Join(chain, previousScript), hookPath); err != nil {
+			return err
+		}
+		return os.Remove(chain)
+	}
+	return nil
+}
+
+// removeChain deletes the scripts we put in the .d directory, leaving
+// others' scripts in place
+func removeChain(dir string) error {
+	chain := chainDir(dir)
+	for _, name := range []string{chainScript, previousScript} {
+		if err := os.Remove(filepath.Join(chain, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if len(chainScripts(chain)) == 0 {
+		os.Remove(chain)
+	}
+	return nil
+}
+
+func chainDir(dir string) string {
+	return filepath.Join(dir, Name+".d")
+}
+
+// foreignScripts lists the scripts in the .d directory that autocommit
+// did not put there
+func foreignScripts(chain string) []string {
+	var names []string
+	for _, name := range chainScripts(chain) {
+		if name != chainScript && name != previousScript {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func chainScripts(chain string) []string {
+	entries, err := os.ReadDir(chain)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// backup saves the current hook. It is only called for hooks without our
+// block, so reinstalling never overwrites the original, and a backup left
+// from an earlier install is replaced by the hook that is there now.
+func backup(hookPath string, content []byte) error {
+	if err := os.WriteFile(hookPath+backupSuffix, content, 0755); err != nil {
+		return fmt.Errorf("failed to back up hook: %w", err)
+	}
+	return nil
+}
+
+func hasBlock(content string) bool {
+	return hasMarked(content, beginMarker, endMarker)
+}
+
+func hasMarked(content, beginMarker, endMarker string) bool {
+	begin := strings.Index(content, beginMarker)
+	return begin >= 0 && strings.Contains(content[begin:], endMarker)
+}
+
+func isRunner(content string) bool {
+	return strings.Contains(content, beginChainMarker) && strings.Contains(content, endChainMarker)
+}
+
+func isLegacy(content string) bool {
+	return content == legacyScript
+}
+
+// replaceBlock swaps our block, markers included, for replacement
+func replaceBlock(content, replacement string) string {
+	return replaceMarked(content, beginMarker, endMarker, replacement)
+}
+
+func replaceMarked(content, beginMarker, endMarker, replacement string) string {
+	begin := strings.Index(content, beginMarker)
+	end := begin + strings.Index(content[begin:], endMarker) + len(endMarker)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return content[:begin] + replacement + content[end:]
+}
+
+// insertBlock puts our block right after the shebang, so it runs before
+// the existing hook and before any exit in it
+func insertBlock(content string) string {
+	return insertAfterShebang(content, block)
+}
+
+func insertAfterShebang(content, block string) string {
+	if strings.HasPrefix(content, "#!") {
+		shebang, rest, _ := strings.Cut(content, "\n")
+		return shebang + "\n" + block + rest
+	}
+	return block + content
+}
+
+var shellShebang = regexp.MustCompile(`^#!.*\b(sh|bash|dash|zsh|ksh)\b`)
+
+// isShellScript reports whether our sh block can run inside the hook.
+// Hooks without a shebang are run by sh.
+func isShellScript(content string) bool {
+	if !strings.HasPrefix(content, "#!") {
+		return true
+	}
+	firstLine, _, _ := strings.Cut(content, "\n")
+	return shellShebang.MatchString(firstLine)
+}
+
+// isEmptyScript reports whether nothing but a shebang and blank lines is left
+func isEmptyScript(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#!") {
+			return false
+		}
+	}
+	return true
+}
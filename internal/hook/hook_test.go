@@ -0,0 +1,375 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const pythonHook = "#!/usr/bin/env python3\nprint('hi')\n"
+
+func writeHook(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readHook(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestInstallFresh(t *testing.T) {
+	dir := t.TempDir()
+	path, err := Install(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#!/bin/sh\n" + block; readHook(t, path) != want {
+		t.Errorf("hook = %q", readHook(t, path))
+	}
+	if Status(dir) != Installed {
+		t.Errorf("status = %v", Status(dir))
+	}
+	if exists(BackupPath(dir)) {
+		t.Error("backup written for a new hook")
+	}
+
+	if err := Uninstall(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if exists(filepath.Join(dir, Name)) {
+		t.Error("empty hook left behind")
+	}
+}
+
+func TestInstallIntoExistingHook(t *testing.T) {
+	dir := t.TempDir()
+	original := "#!/bin/bash\nset -e\necho existing\nexit 0\n"
+	writeHook(t, dir, Name, original)
+
+	path, err := Install(dir, ModeBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readHook(t, path)
+	if want := "#!/bin/bash\n" + block + "set -e\necho existing\nexit 0\n"; got != want {
+		t.Errorf("hook = %q, want block after the shebang", got)
+	}
+	if readHook(t, BackupPath(dir)) != original {
+		t.Error("backup differs from the original hook")
+	}
+
+	// Reinstalling replaces the block in place and keeps the backup
+	if _, err := Install(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if readHook(t, path) != got {
+		t.Error("reinstall changed the hook")
+	}
+	if readHook(t, BackupPath(dir)) != original {
+		t.Error("reinstall overwrote the backup")
+	}
+
+	if err := Uninstall(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if readHook(t, path) != original {
+		t.Errorf("uninstall left %q", readHook(t, path))
+	}
+	if exists(BackupPath(dir)) {
+		t.Error("backup kept after uninstall")
+	}
+}
+
+func TestInstallWithoutShebang(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, Name, "echo plain\n")
+	if _, err := Install(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHook(t, filepath.Join(dir, Name)); got != block+"echo plain\n" {
+		t.Errorf("hook = %q", got)
+	}
+}
+
+func TestInstallReplacesLegacyHook(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, Name, legacyScript)
+	if Status(dir) != Installed {
+		t.Errorf("legacy status = %v", Status(dir))
+	}
+	if _, err := Install(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHook(t, filepath.Join(dir, Name)); got != "#!/bin/sh\n"+block {
+		t.Errorf("hook = %q", got)
+	}
+	if exists(BackupPath(dir)) {
+		t.Error("legacy hook backed up")
+	}
+}
+
+func TestUserHookCallingAutocommit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, Name)
+	original := "#!/bin/sh\n./scripts/check-branch.sh || exit 1\nautocommit generate --hook-mode --output \"$1\"\necho done >> .git/commit.log\n"
+	writeHook(t, dir, Name, original)
+	if Status(dir) != Foreign {
+		t.Errorf("status = %v", Status(dir))
+	}
+
+	if _, err := Install(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHook(t, path); got != insertBlock(original) {
+		t.Errorf("hook = %q", got)
+	}
+	if readHook(t, BackupPath(dir)) != original {
+		t.Error("user hook not backed up")
+	}
+
+	if err := Uninstall(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if readHook(t, path) != original {
+		t.Errorf("uninstall left %q", readHook(t, path))
+	}
+
+	// Without our block there is nothing to uninstall, and the hook stays
+	if err := Uninstall(dir, false); err == nil {
+		t.Error("uninstall of a user hook succeeded")
+	}
+	if readHook(t, path) != original {
+		t.Error("user hook removed")
+	}
+
+	// Chaining keeps the user hook as the previous script
+	if _, err := Install(dir, ModeChain); err != nil {
+		t.Fatal(err)
+	}
+	if readHook(t, filepath.Join(chainDir(dir), previousScript)) != original {
+		t.Error("user hook not kept in the chain")
+	}
+}
+
+func TestLegacyHookIsExact(t *testing.T) {
+	dir := t.TempDir()
+	edited := strings.Replace(legacyScript, "exit 0\nfi\n\nif", "exit 0\nfi\nmake lint\n\nif", 1)
+	writeHook(t, dir, Name, edited)
+	if Status(dir) != Foreign {
+		t.Errorf("edited legacy hook status = %v", Status(dir))
+	}
+	if _, err := Install(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if readHook(t, BackupPath(dir)) != edited {
+		t.Error("edited legacy hook not backed up")
+	}
+}
+
+func TestInstallRefusesNonShellHook(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, Name, pythonHook)
+	if _, err := Install(dir, ""); err == nil || !strings.Contains(err.Error(), "--chain") {
+		t.Fatalf("err = %v, want a hint to use --chain", err)
+	}
+	if readHook(t, filepath.Join(dir, Name)) != pythonHook {
+		t.Error("hook changed")
+	}
+}
+
+func TestChain(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, Name, pythonHook)
+
+	script, err := Install(dir, ModeChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := chainDir(dir)
+	if script != filepath.Join(chain, chainScript) {
+		t.Errorf("script = %s", script)
+	}
+	if readHook(t, filepath.Join(dir, Name)) != runner {
+		t.Error("hook is not the runner")
+	}
+	if readHook(t, filepath.Join(chain, previousScript)) != pythonHook {
+		t.Error("previous hook not moved into the chain")
+	}
+	if Status(dir) != Chained {
+		t.Errorf("status = %v", Status(dir))
+	}
+
+	// A later plain install keeps chaining
+	if _, err := Install(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if readHook(t, filepath.Join(dir, Name)) != runner {
+		t.Error("reinstall replaced the runner")
+	}
+
+	if err := Uninstall(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if readHook(t, filepath.Join(dir, Name)) != pythonHook {
+		t.Error("previous hook not moved back")
+	}
+	if exists(chain) {
+		t.Error("chain directory left behind")
+	}
+}
+
+func TestUnchainKeepsOtherScripts(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Install(dir, ModeChain); err != nil {
+		t.Fatal(err)
+	}
+	writeHook(t, chainDir(dir), "30-lint", "#!/bin/sh\n")
+
+	if err := Uninstall(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if readHook(t, filepath.Join(dir, Name)) != runner {
+		t.Error("runner removed while other scripts remain")
+	}
+	if !exists(filepath.Join(chainDir(dir), "30-lint")) || exists(filepath.Join(chainDir(dir), chainScript)) {
+		t.Errorf("chain = %v", chainScripts(chainDir(dir)))
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, Name, pythonHook)
+	if _, err := Install(dir, ModeChain); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restoring would replace the runner and silently stop 30-lint
+	writeHook(t, chainDir(dir), "30-lint", "#!/bin/sh\n")
+	if err := Uninstall(dir, true); err == nil || !strings.Contains(err.Error(), "30-lint") {
+		t.Fatalf("err = %v, want a refusal naming 30-lint", err)
+	}
+	if readHook(t, filepath.Join(dir, Name)) != runner {
+		t.Error("refused restore changed the hook")
+	}
+
+	os.Remove(filepath.Join(chainDir(dir), "30-lint"))
+	if err := Uninstall(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	if readHook(t, filepath.Join(dir, Name)) != pythonHook {
+		t.Error("backup not restored")
+	}
+	if exists(BackupPath(dir)) || exists(chainDir(dir)) {
+		t.Error("backup or chain left behind")
+	}
+
+	if err := Uninstall(dir, true); err == nil {
+		t.Error("restore without a backup succeeded")
+	}
+}
+
+func TestStaleBackupIsNotRestored(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, Name, "#!/bin/sh\necho a\n")
+	if _, err := Install(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The user edits the hook, so it no longer matches the backup
+	hookPath := filepath.Join(dir, Name)
+	edited := strings.Replace(readHook(t, hookPath), "echo a", "echo edited", 1)
+	writeHook(t, dir, Name, edited)
+	if err := Uninstall(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if exists(BackupPath(dir)) {
+		t.Fatal("stale backup kept after uninstall")
+	}
+
+	// A different hook is installed over later; the backup must be of it
+	writeHook(t, dir, Name, "#!/bin/sh\necho b\n")
+	if _, err := Install(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := Uninstall(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHook(t, hookPath); got != "#!/bin/sh\necho b\n" {
+		t.Errorf("restored %q", got)
+	}
+}
+
+func TestBackupRefreshedOverForeignHook(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, Name+backupSuffix, "#!/bin/sh\necho old\n")
+	writeHook(t, dir, Name, "#!/bin/sh\necho new\n")
+
+	if _, err := Install(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHook(t, BackupPath(dir)); got != "#!/bin/sh\necho new\n" {
+		t.Errorf("backup = %q", got)
+	}
+}
+
+func TestPrefill(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, PrefillName, "#!/bin/sh\necho index\n")
+
+	path, err := InstallPrefill(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !HasPrefill(dir) {
+		t.Error("prefill not detected")
+	}
+	if got := readHook(t, path); got != "#!/bin/sh\n"+prefillBlock+"echo index\n" {
+		t.Errorf("hook = %q", got)
+	}
+	if _, err := InstallPrefill(dir); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(readHook(t, path), beginPrefillMarker) != 1 {
+		t.Error("reinstall duplicated the block")
+	}
+
+	if err := UninstallPrefill(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHook(t, path); got != "#!/bin/sh\necho index\n" {
+		t.Errorf("uninstall left %q", got)
+	}
+}
+
+func TestIsShellScript(t *testing.T) {
+	tests := map[string]bool{
+		"echo hi\n":                   true,
+		"#!/bin/sh\n":                 true,
+		"#!/usr/bin/env bash\n":       true,
+		"#!/bin/zsh -e\n":             true,
+		"#!/usr/bin/env python3\n":    false,
+		"#!/usr/bin/env node\n":       false,
+		"#!/usr/bin/env shellcheck\n": false,
+	}
+	for content, want := range tests {
+		if got := isShellScript(content); got != want {
+			t.Errorf("isShellScript(%q) = %t, want %t", content, got, want)
+		}
+	}
+}
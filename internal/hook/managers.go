@@ -0,0 +1,369 @@
+package hook
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Hook managers that own the repository's git hooks
+const (
+	ManagerPreCommit = "pre-commit"
+	ManagerHusky     = "husky"
+	ManagerLefthook  = "lefthook"
+)
+
+const (
+	preCommitConfig = ".pre-commit-config.yaml"
+	preCommitRepo   = "https://github.com/josinSbazin/AutoCommit"
+	preCommitID     = "autocommit"
+	huskyDir        = ".husky"
+
+	// createdComment marks a default_install_hook_types key that
+	// installPreCommit added, so uninstalling can remove it again
+	createdComment = "# added by autocommit"
+
+	// RunCommand is what managers call; it gets the same arguments as
+	// the prepare-commit-msg hook
+	RunCommand = "autocommit hook run"
+)
+
+var lefthookConfigs = []string{"lefthook.yml", "lefthook.yaml", ".lefthook.yml", ".lefthook.yaml"}
+
+// Manager is a hook manager found in a repository
+type Manager struct {
+	Name       string
+	Config     string // config file or directory, relative to the root
+	Configured bool   // autocommit is set up in it
+}
+
+// DetectManagers lists the hook managers configured in the repository root
+func DetectManagers(root string) []Manager {
+	var managers []Manager
+
+	if _, err := os.Stat(filepath.Join(root, preCommitConfig)); err == nil {
+		doc, _ := readYAML(filepath.Join(root, preCommitConfig))
+		managers = append(managers, Manager{
+			Name:       ManagerPreCommit,
+			Config:     preCommitConfig,
+			Configured: preCommitHook(doc) != nil,
+		})
+	}
+
+	if info, err := os.Stat(filepath.Join(root, huskyDir)); err == nil && info.IsDir() {
+		managers = append(managers, Manager{
+			Name:       ManagerHusky,
+			Config:     huskyDir + "/" + Name,
+			Configured: Status(filepath.Join(root, huskyDir)) == Installed,
+		})
+	}
+
+	if name := lefthookConfig(root); name != "" {
+		doc, _ := readYAML(filepath.Join(root, name))
+		managers = append(managers, Manager{
+			Name:       ManagerLefthook,
+			Config:     name,
+			Configured: mapPath(doc, Name, "commands", "autocommit") != nil,
+		})
+	}
+
+	return managers
+}
+
+// InstallManager sets up autocommit in a hook manager's config and returns
+// the file it changed. rev is the pre-commit revision to pin.
+func InstallManager(root, manager, rev string) (string, error) {
+	switch manager {
+	case ManagerPreCommit:
+		return installPreCommit(root, rev)
+	case ManagerHusky:
+		return Install(filepath.Join(root, huskyDir), ModeBlock)
+	case ManagerLefthook:
+		return installLefthook(root)
+	default:
+		return "", fmt.Errorf("unknown hook manager: %s (use pre-commit, husky or lefthook)", manager)
+	}
+}
+
+// UninstallManager removes autocommit from a hook manager's config
+func UninstallManager(root, manager string) error {
+	switch manager {
+	case ManagerPreCommit:
+		return uninstallPreCommit(root)
+	case ManagerHusky:
+		return Uninstall(filepath.Join(root, huskyDir), false)
+	case ManagerLefthook:
+		name := lefthookConfig(root)
+		if name == "" {
+			return fmt.Errorf("no lefthook config found")
+		}
+		return editYAML(filepath.Join(root, name), func(doc *yaml.Node) error {
+			commands := mapPath(doc, Name, "commands")
+			if mapGet(commands, "autocommit") == nil {
+				return fmt.Errorf("autocommit is not configured in %s", name)
+			}
+			mapDelete(commands, "autocommit")
+			if len(commands.Content) == 0 {
+				mapDelete(mapGet(doc, Name), "commands")
+			}
+			if len(mapGet(doc, Name).Content) == 0 {
+				mapDelete(doc, Name)
+			}
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown hook manager: %s (use pre-commit, husky or lefthook)", manager)
+	}
+}
+
+// installPreCommit adds our repo to .pre-commit-config.yaml and makes
+// pre-commit install the prepare-commit-msg hook type
+func installPreCommit(root, rev string) (string, error) {
+	path := filepath.Join(root, preCommitConfig)
+	err := editYAML(path, func(doc *yaml.Node) error {
+		types := mapGet(doc, "default_install_hook_types")
+		if types == nil {
+			types = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle, LineComment: createdComment}
+			types.Content = append(types.Content, scalarNode("pre-commit"))
+			mapSet(doc, "default_install_hook_types", types)
+		}
+		if !seqContains(types, Name) {
+			types.Content = append(types.Content, scalarNode(Name))
+		}
+
+		if preCommitHook(doc) != nil {
+			return nil
+		}
+
+		repos := mapGet(doc, "repos")
+		if repos == nil {
+			repos = &yaml.Node{Kind: yaml.SequenceNode}
+			mapSet(doc, "repos", repos)
+		}
+
+		hook := &yaml.Node{Kind: yaml.MappingNode}
+		mapSet(hook, "id", scalarNode(preCommitID))
+		hooks := &yaml.Node{Kind: yaml.SequenceNode}
+		hooks.Content = append(hooks.Content, hook)
+
+		repo := &yaml.Node{Kind: yaml.MappingNode}
+		mapSet(repo, "repo", scalarNode(preCommitRepo))
+		mapSet(repo, "rev", scalarNode(rev))
+		mapSet(repo, "hooks", hooks)
+		repos.Content = append(repos.Content, repo)
+		return nil
+	})
+	return path, err
+}
+
+// uninstallPreCommit removes our repo from .pre-commit-config.yaml and
+// the prepare-commit-msg hook type, or the whole default_install_hook_types
+// key if installPreCommit added it
+func uninstallPreCommit(root string) error {
+	return editYAML(filepath.Join(root, preCommitConfig), func(doc *yaml.Node) error {
+		repos := mapGet(doc, "repos")
+		found := false
+		if repos != nil {
+			for i, repo := range repos.Content {
+				if scalar(mapGet(repo, "repo")) == preCommitRepo {
+					repos.Content = append(repos.Content[:i], repos.Content[i+1:]...)
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("autocommit is not configured in %s", preCommitConfig)
+		}
+		if len(repos.Content) == 0 && repos.Style&yaml.FlowStyle == 0 {
+			mapDelete(doc, "repos")
+		}
+
+		types := mapGet(doc, "default_install_hook_types")
+		if types == nil {
+			return nil
+		}
+		if types.LineComment == createdComment {
+			mapDelete(doc, "default_install_hook_types")
+			return nil
+		}
+		for i, n := range types.Content {
+			if n.Value == Name {
+				types.Content = append(types.Content[:i], types.Content[i+1:]...)
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// installLefthook adds a prepare-commit-msg command to the lefthook config
+func installLefthook(root string) (string, error) {
+	name := lefthookConfig(root)
+	if name == "" {
+		name = lefthookConfigs[0]
+	}
+	path := filepath.Join(root, name)
+
+	err := editYAML(path, func(doc *yaml.Node) error {
+		hookNode := mapGet(doc, Name)
+		if hookNode == nil {
+			hookNode = &yaml.Node{Kind: yaml.MappingNode}
+			mapSet(doc, Name, hookNode)
+		}
+		commands := mapGet(hookNode, "commands")
+		if commands == nil {
+			commands = &yaml.Node{Kind: yaml.MappingNode}
+			mapSet(hookNode, "commands", commands)
+		}
+
+		command := &yaml.Node{Kind: yaml.MappingNode}
+		mapSet(command, "run", scalarNode(RunCommand+" {1} {2}"))
+		mapSet(commands, "autocommit", command)
+		return nil
+	})
+	return path, err
+}
+
+func lefthookConfig(root string) string {
+	for _, name := range lefthookConfigs {
+		if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// preCommitHook finds our hook entry in a pre-commit config
+func preCommitHook(doc *yaml.Node) *yaml.Node {
+	repos := mapGet(doc, "repos")
+	if repos == nil {
+		return nil
+	}
+	for _, repo := range repos.Content {
+		hooks := mapGet(repo, "hooks")
+		if hooks == nil || scalar(mapGet(repo, "repo")) != preCommitRepo {
+			continue
+		}
+		for _, hook := range hooks.Content {
+			if scalar(mapGet(hook, "id")) == preCommitID {
+				return hook
+			}
+		}
+	}
+	return nil
+}
+
+// readYAML returns the top-level mapping of a YAML file, empty if the file
+// doesn't exist
+func readYAML(path string) (*yaml.Node, error) {
+	_, doc, err := loadYAML(path)
+	return doc, err
+}
+
+// loadYAML returns a YAML file's document node and its top-level mapping
+func loadYAML(path string) (*yaml.Node, *yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	var file yaml.Node
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(file.Content) == 0 {
+		doc := &yaml.Node{Kind: yaml.MappingNode}
+		return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{doc}}, doc, nil
+	}
+	if file.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("%s is not a YAML mapping", path)
+	}
+	return &file, file.Content[0], nil
+}
+
+// editYAML applies edit to a YAML file, keeping its comments
+func editYAML(path string, edit func(doc *yaml.Node) error) error {
+	file, doc, err := loadYAML(path)
+	if err != nil {
+		return err
+	}
+	if err := edit(doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return os.Remove(path)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(file); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func mapGet(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func mapPath(m *yaml.Node, keys ...string) *yaml.Node {
+	for _, key := range keys {
+		m = mapGet(m, key)
+	}
+	return m
+}
+
+func mapSet(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, scalarNode(key), value)
+}
+
+func mapDelete(m *yaml.Node, key string) {
+	if m == nil {
+		return
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func seqContains(seq *yaml.Node, value string) bool {
+	for _, n := range seq.Content {
+		if n.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func scalar(n *yaml.Node) string {
+	if n == nil {
+		return ""
+	}
+	return n.Value
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,118 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const preCommitComments = `# See https://pre-commit.com
+default_stages: [pre-commit] # keep fast
+repos:
+  # formatting
+  - repo: https://github.com/pre-commit/pre-commit-hooks
+    rev: v4.6.0
+    hooks:
+      - id: trailing-whitespace
+      - id: end-of-file-fixer # fixes EOF
+`
+
+func TestPreCommitRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string // empty for no config file
+		types  string // default_install_hook_types after install
+	}{
+		{
+			name:   "comments",
+			config: preCommitComments,
+			types:  "default_install_hook_types: [pre-commit, prepare-commit-msg] # added by autocommit\n",
+		},
+		{
+			name:   "flow hook types",
+			config: "default_install_hook_types: [pre-commit, commit-msg] # both\n" + preCommitComments,
+			types:  "default_install_hook_types: [pre-commit, commit-msg, prepare-commit-msg] # both\n",
+		},
+		{
+			name:   "block hook types",
+			config: "default_install_hook_types:\n  # checks\n  - pre-commit\n  - pre-push\n" + preCommitComments,
+			types:  "  - pre-push\n  - prepare-commit-msg\n",
+		},
+		{
+			name:  "no config",
+			types: "default_install_hook_types: [pre-commit, prepare-commit-msg] # added by autocommit\n",
+		},
+	}
+
+	for _, tt := range tests {
+		root := t.TempDir()
+		path := filepath.Join(root, preCommitConfig)
+		if tt.config != "" {
+			if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := InstallManager(root, ManagerPreCommit, "v1.2.3"); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		installed := readHook(t, path)
+		if !strings.Contains(installed, tt.types) || !strings.Contains(installed, "rev: v1.2.3\n    hooks:\n      - id: autocommit\n") {
+			t.Errorf("%s: installed config:\n%s", tt.name, installed)
+		}
+		if managers := DetectManagers(root); len(managers) != 1 || !managers[0].Configured {
+			t.Errorf("%s: managers = %+v", tt.name, managers)
+		}
+
+		if err := UninstallManager(root, ManagerPreCommit); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.config == "" {
+			if exists(path) {
+				t.Errorf("%s: uninstall left\n%s", tt.name, readHook(t, path))
+			}
+			continue
+		}
+		if got := readHook(t, path); got != tt.config {
+			t.Errorf("%s: uninstall left\n%s\nwant\n%s", tt.name, got, tt.config)
+		}
+	}
+}
+
+func TestPreCommitUninstallNotConfigured(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, preCommitConfig)
+	if err := os.WriteFile(path, []byte(preCommitComments), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UninstallManager(root, ManagerPreCommit); err == nil {
+		t.Error("uninstall succeeded without autocommit configured")
+	}
+	if got := readHook(t, path); got != preCommitComments {
+		t.Errorf("config changed:\n%s", got)
+	}
+}
+
+func TestLefthookRoundTrip(t *testing.T) {
+	config := "# lefthook\npre-commit:\n  commands:\n    lint:\n      run: make lint # fast\n"
+	root := t.TempDir()
+	path := filepath.Join(root, "lefthook.yml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := InstallManager(root, ManagerLefthook, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHook(t, path); !strings.HasPrefix(got, config) || !strings.Contains(got, "run: "+RunCommand+" {1} {2}") {
+		t.Errorf("installed config:\n%s", got)
+	}
+
+	if err := UninstallManager(root, ManagerLefthook); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHook(t, path); got != config {
+		t.Errorf("uninstall left\n%s\nwant\n%s", got, config)
+	}
+}
@@ -0,0 +1,86 @@
+package hook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PrefillName is the git hook that starts prefill jobs. Git runs it
+// whenever the index is written, e.g. by git add.
+const PrefillName = "post-index-change"
+
+// PrefillEnv is set for the prefill job, so index writes it causes don't
+// start another one
+const PrefillEnv = "AUTOCOMMIT_PREFILL"
+
+const (
+	beginPrefillMarker = "# >>> autocommit prefill >>>"
+	endPrefillMarker   = "# <<< autocommit prefill <<<"
+)
+
+const prefillBlock = beginPrefillMarker + `
+# Managed by autocommit. Remove with 'autocommit hook uninstall'.
+if [ -z "$` + PrefillEnv + `" ]; then
+    (autocommit prefill >/dev/null 2>&1 &)
+fi
+` + endPrefillMarker + "\n"
+
+// HasPrefill reports whether the prefill hook is installed in dir
+func HasPrefill(dir string) bool {
+	content, err := os.ReadFile(filepath.Join(dir, PrefillName))
+	return err == nil && hasMarked(string(content), beginPrefillMarker, endPrefillMarker)
+}
+
+// InstallPrefill adds a block to the post-index-change hook in dir that
+// starts 'autocommit prefill' in the background and returns its path
+func InstallPrefill(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	hookPath := filepath.Join(dir, PrefillName)
+
+	content, err := os.ReadFile(hookPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	exists := err == nil
+
+	switch {
+	case !exists:
+		content = []byte("#!/bin/sh\n" + prefillBlock)
+	case hasMarked(string(content), beginPrefillMarker, endPrefillMarker):
+		content = []byte(replaceMarked(string(content), beginPrefillMarker, endPrefillMarker, prefillBlock))
+	case !isShellScript(string(content)):
+		return "", fmt.Errorf("existing hook at %s is not a shell script", hookPath)
+	default:
+		content = []byte(insertAfterShebang(string(content), prefillBlock))
+	}
+
+	if err := os.WriteFile(hookPath, content, 0755); err != nil {
+		return "", err
+	}
+	return hookPath, nil
+}
+
+// UninstallPrefill removes our block from the post-index-change hook in
+// dir, deleting the hook when nothing else is left in it
+func UninstallPrefill(dir string) error {
+	hookPath := filepath.Join(dir, PrefillName)
+	content, err := os.ReadFile(hookPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !hasMarked(string(content), beginPrefillMarker, endPrefillMarker) {
+		return nil
+	}
+
+	rest := replaceMarked(string(content), beginPrefillMarker, endPrefillMarker, "")
+	if isEmptyScript(rest) {
+		return os.Remove(hookPath)
+	}
+	return os.WriteFile(hookPath, []byte(rest), 0755)
+}
@@ -0,0 +1,223 @@
+package lsp
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/message"
+)
+
+// scissors follows the comment character in git's marker for the verbose
+// diff at the end of the buffer; nothing below it is part of the message
+const scissors = " ------------------------ >8 ------------------------"
+
+// buffer is a commit message file as git leaves it for the editor: the
+// message followed by comments
+type buffer struct {
+	lines   []string
+	version int
+	// commentChar is core.commentChar, empty for auto; comment is what
+	// comment lines start with in this text
+	commentChar string
+	comment     string
+}
+
+func newBuffer(text string, version int, commentChar string) buffer {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	b := buffer{lines: strings.Split(text, "\n"), version: version, commentChar: commentChar}
+	b.comment = commentChar
+	if b.comment == "" {
+		b.comment = guessCommentChar(b.lines)
+	}
+	return b
+}
+
+// guessCommentChar finds the character git picked for auto: the
+// candidate that starts the most lines, since git's own comments fill the
+// end of the buffer
+func guessCommentChar(lines []string) string {
+	best, most := "#", 0
+	for _, c := range git.AutoCommentChars {
+		n := 0
+		for _, line := range lines {
+			if strings.HasPrefix(line, string(c)) {
+				n++
+			}
+		}
+		if n > most {
+			best, most = string(c), n
+		}
+	}
+	return best
+}
+
+func (b buffer) isComment(line string) bool {
+	return strings.HasPrefix(line, b.comment)
+}
+
+// messageLines returns the indexes of the lines git keeps in the commit
+// message, leading blank lines excluded
+func (b buffer) messageLines() []int {
+	var kept []int
+	for i, line := range b.lines {
+		if line == b.comment+scissors {
+			break
+		}
+		if b.isComment(line) {
+			continue
+		}
+		if len(kept) == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+		kept = append(kept, i)
+	}
+	return kept
+}
+
+// message returns the text git would commit
+func (b buffer) message() string {
+	var sb strings.Builder
+	for _, i := range b.messageLines() {
+		sb.WriteString(b.lines[i])
+		sb.WriteString("\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// diagnostics turns validation issues into diagnostics on buffer lines
+func (b buffer) diagnostics(issues []message.Issue) []diagnostic {
+	kept := b.messageLines()
+	diags := []diagnostic{}
+	for _, issue := range issues {
+		line := 0
+		if len(kept) > 0 {
+			n := min(max(issue.Line, 1), len(kept))
+			line = kept[n-1]
+		}
+
+		severity := severityWarning
+		if issue.Severity == message.SeverityError {
+			severity = severityError
+		}
+
+		diags = append(diags, diagnostic{
+			Range: lspRange{
+				Start: position{Line: line},
+				End:   position{Line: line, Character: utf16Len(b.line(line))},
+			},
+			Severity: severity,
+			Code:     issue.Rule,
+			Source:   "autocommit",
+			Message:  issue.Message,
+		})
+	}
+	return diags
+}
+
+// messageRange covers the message part of the buffer, up to the first
+// comment line, so a generated message can replace it
+func (b buffer) messageRange() (r lspRange, beforeComments bool) {
+	for i, line := range b.lines {
+		if b.isComment(line) {
+			return lspRange{End: position{Line: i}}, true
+		}
+	}
+	last := len(b.lines) - 1
+	return lspRange{End: position{Line: last, Character: utf16Len(b.lines[last])}}, false
+}
+
+func (b buffer) line(i int) string {
+	if i < 0 || i >= len(b.lines) {
+		return ""
+	}
+	return b.lines[i]
+}
+
+// subjectLine returns the index of the line that holds the subject
+func (b buffer) subjectLine() int {
+	if kept := b.messageLines(); len(kept) > 0 {
+		return kept[0]
+	}
+	for i, line := range b.lines {
+		if !b.isComment(line) {
+			return i
+		}
+	}
+	return 0
+}
+
+var (
+	typePrefixRe  = regexp.MustCompile(`^[a-zA-Z]*$`)
+	scopePrefixRe = regexp.MustCompile(`^[a-zA-Z]+\([^()]*$`)
+)
+
+// completionContext tells what is being typed at pos: "type", "scope" or
+// nothing
+func (b buffer) completionContext(pos position) string {
+	if pos.Line != b.subjectLine() {
+		return ""
+	}
+	prefix := utf16Prefix(b.line(pos.Line), pos.Character)
+	switch {
+	case typePrefixRe.MatchString(prefix):
+		return "type"
+	case scopePrefixRe.MatchString(prefix):
+		return "scope"
+	}
+	return ""
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// utf16Prefix returns the part of s before an LSP character offset
+func utf16Prefix(s string, character int) string {
+	units := 0
+	for i, r := range s {
+		if units >= character {
+			return s[:i]
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return s
+}
+
+// uriPath converts a file:// URI to a path
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path)
+}
+
+// repoDir finds the working tree a commit message file belongs to.
+// COMMIT_EDITMSG lives in .git, or in .git/worktrees/<name> for linked
+// worktrees, whose gitdir file points back at the worktree.
+func repoDir(path string) string {
+	dir := filepath.Dir(path)
+	for d := dir; ; d = filepath.Dir(d) {
+		if filepath.Base(filepath.Dir(d)) == "worktrees" {
+			if data, err := os.ReadFile(filepath.Join(d, "gitdir")); err == nil {
+				return filepath.Dir(strings.TrimSpace(string(data)))
+			}
+		}
+		if filepath.Base(d) == ".git" {
+			return filepath.Dir(d)
+		}
+		if filepath.Dir(d) == d {
+			return dir
+		}
+	}
+}
@@ -0,0 +1,411 @@
+// Package lsp is a language server for commit message buffers such as
+// COMMIT_EDITMSG. It lints the message against the configured rules,
+// completes Conventional Commits types and scopes, and offers code
+// actions that generate the message.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/message"
+	"github.com/josinSbazin/AutoCommit/internal/rpc"
+)
+
+// Commands offered as code actions
+const (
+	CommandGenerate   = "autocommit.generate"
+	CommandRegenerate = "autocommit.regenerate"
+)
+
+// typeDocs describes the common Conventional Commits types
+var typeDocs = map[string]string{
+	"feat":     "A new feature",
+	"fix":      "A bug fix",
+	"docs":     "Documentation only changes",
+	"style":    "Formatting, whitespace and other changes that don't affect meaning",
+	"refactor": "A code change that neither fixes a bug nor adds a feature",
+	"test":     "Adding or correcting tests",
+	"chore":    "Maintenance that doesn't touch source or tests",
+	"perf":     "A code change that improves performance",
+	"ci":       "Changes to CI configuration and scripts",
+	"build":    "Changes to the build system or dependencies",
+	"revert":   "Reverts a previous commit",
+}
+
+// Server handles one editor session
+type Server struct {
+	generate rpc.Generator
+	conn     *conn
+
+	mu      sync.Mutex
+	docs    map[string]buffer
+	rootDir string
+	nextID  int
+	// versionedEdits is set when the client accepts documentChanges
+	versionedEdits bool
+}
+
+// NewServer returns a server that uses generate for code actions
+func NewServer(generate rpc.Generator) *Server {
+	return &Server{generate: generate, docs: map[string]buffer{}}
+}
+
+// Run serves requests from r, writing responses to w, until the client
+// sends exit or r is closed
+func (s *Server) Run(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		msg, err := s.conn.read()
+		var rpcErr *rpc.Error
+		if errors.As(err, &rpcErr) {
+			s.conn.write(&rpc.Message{ID: rpc.NullID(), Error: rpcErr})
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case msg.Method == "":
+			// A response to one of our requests, such as applyEdit
+		case msg.Method == "exit":
+			return nil
+		case msg.Method == "workspace/executeCommand":
+			// Generation takes seconds; keep answering in the meantime
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.respond(msg, s.executeCommand(ctx, msg.Params))
+			}()
+		default:
+			s.respond(msg, s.dispatch(msg))
+		}
+	}
+}
+
+// result is a handler's outcome: a value or an error
+type result struct {
+	value any
+	err   error
+}
+
+func (s *Server) respond(msg *rpc.Message, res result) {
+	if msg.ID == nil {
+		return
+	}
+
+	reply := &rpc.Message{ID: msg.ID}
+	if res.err != nil {
+		var rpcErr *rpc.Error
+		if !errors.As(res.err, &rpcErr) {
+			rpcErr = &rpc.Error{Code: rpc.CodeInternalError, Message: res.err.Error()}
+		}
+		reply.Error = rpcErr
+	} else {
+		data, err := json.Marshal(res.value)
+		if err != nil {
+			reply.Error = &rpc.Error{Code: rpc.CodeInternalError, Message: err.Error()}
+		} else {
+			reply.Result = data
+		}
+	}
+	s.conn.write(reply)
+}
+
+func (s *Server) dispatch(msg *rpc.Message) result {
+	switch msg.Method {
+	case "initialize":
+		var params initializeParams
+		if err := decode(msg.Params, &params); err != nil {
+			return result{err: err}
+		}
+		s.mu.Lock()
+		s.rootDir = uriPath(params.RootURI)
+		s.versionedEdits = params.Capabilities.Workspace.WorkspaceEdit.DocumentChanges
+		s.mu.Unlock()
+		return result{value: map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   syncFull,
+				"codeActionProvider": true,
+				"completionProvider": map[string]any{"triggerCharacters": []string{"("}},
+				"executeCommandProvider": map[string]any{
+					"commands": []string{CommandGenerate, CommandRegenerate},
+				},
+			},
+			"serverInfo": map[string]string{"name": "autocommit"},
+		}}
+
+	case "initialized":
+		return result{}
+
+	case "shutdown":
+		return result{}
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := decode(msg.Params, &params); err != nil {
+			return result{err: err}
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Version, params.TextDocument.Text)
+		return result{}
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := decode(msg.Params, &params); err != nil {
+			return result{err: err}
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.TextDocument.Version, params.ContentChanges[n-1].Text)
+		}
+		return result{}
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := decode(msg.Params, &params); err != nil {
+			return result{err: err}
+		}
+		s.mu.Lock()
+		delete(s.docs, params.TextDocument.URI)
+		s.mu.Unlock()
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+		return result{}
+
+	case "textDocument/codeAction":
+		var params codeActionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return result{err: err}
+		}
+		return result{value: s.codeActions(params.TextDocument.URI)}
+
+	case "textDocument/completion":
+		var params completionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return result{err: err}
+		}
+		return result{value: s.completions(params.TextDocument.URI, params.Position)}
+	}
+
+	// Notifications we don't handle, such as $/cancelRequest, are ignored
+	if msg.ID == nil {
+		return result{}
+	}
+	return result{err: &rpc.Error{Code: rpc.CodeMethodNotFound, Message: "method not found: " + msg.Method}}
+}
+
+// update stores the new text of a document and republishes its
+// diagnostics. core.commentChar is read when the document opens.
+func (s *Server) update(uri string, version int, text string) {
+	s.mu.Lock()
+	prev, open := s.docs[uri]
+	s.mu.Unlock()
+
+	commentChar := prev.commentChar
+	if !open {
+		commentChar = git.CommentChar(s.dir(uri))
+	}
+	buf := newBuffer(text, version, commentChar)
+	s.mu.Lock()
+	s.docs[uri] = buf
+	s.mu.Unlock()
+
+	diags := []diagnostic{}
+	// A fresh buffer has no message yet; that's not worth an error
+	if msg := buf.message(); msg != "" {
+		diags = buf.diagnostics(message.Validate(s.config(uri), msg))
+	}
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+func (s *Server) codeActions(uri string) []codeAction {
+	s.mu.Lock()
+	buf, ok := s.docs[uri]
+	s.mu.Unlock()
+	if !ok {
+		return []codeAction{}
+	}
+
+	action := codeAction{
+		Title:   "Generate commit message",
+		Kind:    "source",
+		Command: &command{Title: "Generate commit message", Command: CommandGenerate, Arguments: []any{uri}},
+	}
+	if buf.message() != "" {
+		action.Title = "Regenerate commit message"
+		action.Command = &command{Title: action.Title, Command: CommandRegenerate, Arguments: []any{uri}}
+	}
+	return []codeAction{action}
+}
+
+func (s *Server) completions(uri string, pos position) []completionItem {
+	s.mu.Lock()
+	buf, ok := s.docs[uri]
+	s.mu.Unlock()
+	items := []completionItem{}
+	if !ok {
+		return items
+	}
+
+	cfg := s.config(uri)
+	switch buf.completionContext(pos) {
+	case "type":
+		for _, t := range cfg.Conventional.AllowedTypes {
+			items = append(items, completionItem{
+				Label:         t,
+				Kind:          completionKindKeyword,
+				Detail:        "Conventional Commits type",
+				Documentation: typeDocs[t],
+			})
+		}
+	case "scope":
+		for _, scope := range cfg.Conventional.AllowedScopes {
+			items = append(items, completionItem{
+				Label:  scope,
+				Kind:   completionKindModule,
+				Detail: "Allowed scope",
+			})
+		}
+	}
+	return items
+}
+
+// executeCommand generates a message and asks the editor to put it in
+// place of the current one, keeping git's comments below it
+func (s *Server) executeCommand(ctx context.Context, raw json.RawMessage) result {
+	var params executeCommandParams
+	if err := decode(raw, &params); err != nil {
+		return result{err: err}
+	}
+	if params.Command != CommandGenerate && params.Command != CommandRegenerate {
+		return result{err: &rpc.Error{Code: rpc.CodeInvalidParams, Message: "unknown command: " + params.Command}}
+	}
+
+	var uri string
+	if len(params.Arguments) == 0 || json.Unmarshal(params.Arguments[0], &uri) != nil {
+		return result{err: &rpc.Error{Code: rpc.CodeInvalidParams, Message: "expected the document URI as argument"}}
+	}
+
+	s.mu.Lock()
+	buf, ok := s.docs[uri]
+	s.mu.Unlock()
+	if !ok {
+		return result{err: &rpc.Error{Code: rpc.CodeInvalidParams, Message: "document is not open: " + uri}}
+	}
+
+	text, err := s.generate(ctx, s.dir(uri), params.Command == CommandRegenerate)
+	if err != nil {
+		s.notify("window/showMessage", showMessageParams{
+			Type:    messageTypeError,
+			Message: "autocommit: " + err.Error(),
+		})
+		return result{err: fmt.Errorf("failed to generate message: %w", err)}
+	}
+
+	// The user may have typed while the provider answered; their text
+	// wins over the generated one
+	s.mu.Lock()
+	current, ok := s.docs[uri]
+	versioned := s.versionedEdits
+	s.mu.Unlock()
+	if !ok || current.version != buf.version {
+		s.notify("window/showMessage", showMessageParams{
+			Type:    messageTypeWarning,
+			Message: "autocommit: the message was edited while generating, so it was left as is",
+		})
+		return result{}
+	}
+
+	r, beforeComments := current.messageRange()
+	newText := text + "\n"
+	if beforeComments {
+		newText += "\n"
+	}
+	edits := []textEdit{{Range: r, NewText: newText}}
+
+	var edit applyEditParams
+	edit.Label = "Generate commit message"
+	if versioned {
+		// The client rejects the edit if the document changed after all
+		edit.Edit.DocumentChanges = []textDocumentEdit{{
+			TextDocument: versionedTextDocumentIdentifier{URI: uri, Version: current.version},
+			Edits:        edits,
+		}}
+	} else {
+		edit.Edit.Changes = map[string][]textEdit{uri: edits}
+	}
+	s.request("workspace/applyEdit", edit)
+	return result{}
+}
+
+// dir returns the working tree for a document, falling back to the
+// workspace root and the current directory
+func (s *Server) dir(uri string) string {
+	if path := uriPath(uri); path != "" {
+		return repoDir(path)
+	}
+	s.mu.Lock()
+	root := s.rootDir
+	s.mu.Unlock()
+	if root != "" {
+		return root
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// config loads the config that applies to a document's repository
+func (s *Server) config(uri string) *config.Config {
+	cfg, err := config.LoadDir(s.dir(uri))
+	if err != nil {
+		return config.Default()
+	}
+	return cfg
+}
+
+func (s *Server) notify(method string, params any) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	s.conn.write(&rpc.Message{Method: method, Params: data})
+}
+
+// request sends a request to the client; its response is ignored
+func (s *Server) request(method string, params any) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.nextID++
+	id := json.RawMessage(fmt.Sprintf(`"autocommit-%d"`, s.nextID))
+	s.mu.Unlock()
+	s.conn.write(&rpc.Message{ID: &id, Method: method, Params: data})
+}
+
+func decode(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &rpc.Error{Code: rpc.CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josinSbazin/AutoCommit/internal/rpc"
+)
+
+// session drives a Server over pipes like an editor would
+type session struct {
+	t   *testing.T
+	in  io.WriteCloser
+	out chan rpc.Message
+}
+
+func newSession(t *testing.T, generate rpc.Generator) *session {
+	t.Helper()
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := &session{t: t, in: inW, out: make(chan rpc.Message, 16)}
+
+	go NewServer(generate).Run(context.Background(), inR, outW)
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			header, err := textproto.NewReader(r).ReadMIMEHeader()
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(header.Get("Content-Length"))
+			body := make([]byte, n)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			var msg rpc.Message
+			json.Unmarshal(body, &msg)
+			s.out <- msg
+		}
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return s
+}
+
+func (s *session) send(id int, method string, params any) {
+	s.t.Helper()
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if id > 0 {
+		msg["id"] = id
+	}
+	body, _ := json.Marshal(msg)
+	fmt.Fprintf(s.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// next returns the next message from the server with the given method,
+// or the response when method is empty
+func (s *session) next(method string) rpc.Message {
+	s.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-s.out:
+			if msg.Method == method {
+				return msg
+			}
+		case <-timeout:
+			s.t.Fatalf("no %q message", method)
+		}
+	}
+}
+
+const commitURI = "file:///tmp/repo/.git/COMMIT_EDITMSG"
+
+func TestGenerateDropsEditAfterChange(t *testing.T) {
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	s := newSession(t, func(ctx context.Context, dir string, regenerate bool) (string, error) {
+		started <- struct{}{}
+		<-release
+		return "feat: generated", nil
+	})
+
+	s.send(1, "initialize", map[string]any{
+		"capabilities": map[string]any{"workspace": map[string]any{"workspaceEdit": map[string]any{"documentChanges": true}}},
+	})
+	s.next("")
+	s.send(0, "textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": commitURI, "version": 1, "text": "\n# Please enter the commit message\n"},
+	})
+	s.next("textDocument/publishDiagnostics")
+
+	s.send(2, "workspace/executeCommand", map[string]any{"command": CommandGenerate, "arguments": []string{commitURI}})
+	<-started
+	s.send(0, "textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": commitURI, "version": 2},
+		"contentChanges": []map[string]string{{"text": "fix: typed by hand\n# Please enter the commit message\n"}},
+	})
+	s.next("textDocument/publishDiagnostics")
+	close(release)
+
+	if msg := s.next("window/showMessage"); !strings.Contains(string(msg.Params), "edited while generating") {
+		t.Errorf("showMessage = %s", msg.Params)
+	}
+	s.next("")
+
+	// Without a change in between the edit is sent for the current version
+	s.send(3, "workspace/executeCommand", map[string]any{"command": CommandGenerate, "arguments": []string{commitURI}})
+	var edit applyEditParams
+	json.Unmarshal(s.next("workspace/applyEdit").Params, &edit)
+	if len(edit.Edit.DocumentChanges) != 1 || edit.Edit.Changes != nil {
+		t.Fatalf("edit = %+v", edit.Edit)
+	}
+	dc := edit.Edit.DocumentChanges[0]
+	if dc.TextDocument.Version != 2 || dc.Edits[0].NewText != "feat: generated\n\n" || dc.Edits[0].Range.End.Line != 1 {
+		t.Errorf("document change = %+v", dc)
+	}
+}
+
+func TestBufferCommentChar(t *testing.T) {
+	text := "fix: keep # in the subject\n\n; Please enter the commit message\n; ------------------------ >8 ------------------------\ndiff --git a/x b/x\n"
+	for _, commentChar := range []string{";", ""} {
+		b := newBuffer(text, 1, commentChar)
+		if got := b.message(); got != "fix: keep # in the subject" {
+			t.Errorf("commentChar %q: message = %q", commentChar, got)
+		}
+		if r, before := b.messageRange(); !before || r.End.Line != 2 {
+			t.Errorf("commentChar %q: range ends at %d", commentChar, r.End.Line)
+		}
+	}
+
+	// With the default, a # line is a comment and ; is text
+	if got := newBuffer("# comment\n; text\n", 1, "#").message(); got != "; text" {
+		t.Errorf("message = %q", got)
+	}
+}
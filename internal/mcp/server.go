@@ -0,0 +1,154 @@
+// Package mcp is a Model Context Protocol server over stdio. It lets
+// coding agents read the staged changes and history and write commits that
+// follow the repository's .autocommit.yml rules.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/josinSbazin/AutoCommit/internal/rpc"
+)
+
+// protocolVersions are the MCP revisions this server speaks, newest first
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// maxMessageSize bounds a single message; diffs in tool calls can be large
+const maxMessageSize = 16 << 20
+
+// Server answers one MCP client on stdio
+type Server struct {
+	version  string
+	dir      string
+	generate rpc.Generator
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewServer returns a server for the repository in dir. version is
+// reported to clients.
+func NewServer(version, dir string, generate rpc.Generator) *Server {
+	return &Server{version: version, dir: dir, generate: generate}
+}
+
+// Run reads newline-delimited JSON-RPC messages from r until it is closed
+func (s *Server) Run(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.w = w
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg rpc.Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			s.write(&rpc.Message{ID: rpc.NullID(), Error: &rpc.Error{Code: rpc.CodeParseError, Message: err.Error()}})
+			continue
+		}
+		if msg.Method == "" {
+			continue // a response; we send no requests
+		}
+
+		value, err := s.dispatch(ctx, &msg)
+		if msg.ID == nil {
+			continue
+		}
+		s.reply(msg.ID, value, err)
+	}
+	return scanner.Err()
+}
+
+func (s *Server) dispatch(ctx context.Context, msg *rpc.Message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		version := protocolVersions[0]
+		if slices.Contains(protocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": "autocommit", "version": s.version},
+			"instructions": "Use these tools to write commits for this repository. " +
+				"Call get_staged_diff and generate_commit_message, check the message with " +
+				"lint_commit_message, then create_commit. Messages must follow the repository's rules.",
+		}, nil
+
+	case "ping":
+		return map[string]any{}, nil
+
+	case "tools/list":
+		return map[string]any{"tools": toolList()}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.callTool(ctx, params.Name, params.Arguments)
+	}
+
+	if msg.ID == nil {
+		return nil, nil // notifications/initialized and others
+	}
+	return nil, &rpc.Error{Code: rpc.CodeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+func (s *Server) reply(id *json.RawMessage, value any, err error) {
+	msg := &rpc.Message{ID: id}
+	if err != nil {
+		var rpcErr *rpc.Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpc.Error{Code: rpc.CodeInvalidParams, Message: err.Error()}
+		}
+		msg.Error = rpcErr
+	} else {
+		data, merr := json.Marshal(value)
+		if merr != nil {
+			msg.Error = &rpc.Error{Code: rpc.CodeInvalidParams, Message: merr.Error()}
+		} else {
+			msg.Result = data
+		}
+	}
+	s.write(msg)
+}
+
+func (s *Server) write(msg *rpc.Message) {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(append(data, '\n'))
+}
+
+func decode(raw json.RawMessage, v any) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &rpc.Error{Code: rpc.CodeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
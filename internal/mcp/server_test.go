@@ -0,0 +1,198 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/rpc"
+)
+
+// session drives a Server over pipes like an agent would
+type session struct {
+	t   *testing.T
+	dir string
+	in  io.WriteCloser
+	out chan rpc.Message
+}
+
+// newSession starts a server for a new repository with one commit
+func newSession(t *testing.T) *session {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "Test")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+
+	dir := t.TempDir()
+	s := &session{t: t, dir: dir, out: make(chan rpc.Message, 16)}
+	s.git("init", "-q")
+	s.write("README.md", "hello\n")
+	s.git("add", "-A")
+	s.git("commit", "-q", "-m", "init")
+
+	git.SetDefault(&git.ExecRepository{Dir: dir})
+	t.Cleanup(func() { git.SetDefault(nil) })
+
+	generate := func(ctx context.Context, dir string, regenerate bool) (string, error) {
+		return "feat: generated", nil
+	}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s.in = inW
+
+	go NewServer("test", dir, generate).Run(context.Background(), inR, outW)
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			var msg rpc.Message
+			json.Unmarshal(scanner.Bytes(), &msg)
+			s.out <- msg
+		}
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return s
+}
+
+func (s *session) git(args ...string) string {
+	s.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = s.dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		s.t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (s *session) write(path, content string) {
+	s.t.Helper()
+	if err := os.WriteFile(filepath.Join(s.dir, path), []byte(content), 0644); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// call sends a tools/call request and returns the tool's result
+func (s *session) call(id int, name string, arguments any) toolResult {
+	s.t.Helper()
+	msg := map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name, "arguments": arguments},
+	}
+	body, _ := json.Marshal(msg)
+	s.in.Write(append(body, '\n'))
+
+	select {
+	case reply := <-s.out:
+		if reply.Error != nil {
+			s.t.Fatalf("%s: %s", name, reply.Error.Message)
+		}
+		var result toolResult
+		if err := json.Unmarshal(reply.Result, &result); err != nil || len(result.Content) != 1 {
+			s.t.Fatalf("%s: result %s", name, reply.Result)
+		}
+		return result
+	case <-time.After(5 * time.Second):
+		s.t.Fatalf("%s: no response", name)
+	}
+	return toolResult{}
+}
+
+func TestCreateCommit(t *testing.T) {
+	s := newSession(t)
+	head := s.git("rev-parse", "HEAD")
+
+	// Nothing staged
+	result := s.call(1, "create_commit", map[string]any{"message": "docs: update readme"})
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "no staged changes") {
+		t.Errorf("nothing staged: %+v", result)
+	}
+
+	s.write("README.md", "hello, world\n")
+	s.git("add", "README.md")
+
+	// A message that breaks the conventional rules is refused
+	result = s.call(2, "create_commit", map[string]any{"message": "Updated the readme."})
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "breaks the repository's rules") ||
+		!strings.Contains(result.Content[0].Text, "allow_invalid") {
+		t.Errorf("invalid message: %+v", result)
+	}
+	if got := s.git("rev-parse", "HEAD"); got != head {
+		t.Fatal("invalid message was committed")
+	}
+
+	// A valid message is committed
+	result = s.call(3, "create_commit", map[string]any{"message": "docs: greet the world\n"})
+	if result.IsError {
+		t.Fatalf("valid message: %s", result.Content[0].Text)
+	}
+	var commit struct {
+		Committed bool   `json:"committed"`
+		Hash      string `json:"hash"`
+		Subject   string `json:"subject"`
+	}
+	json.Unmarshal([]byte(result.Content[0].Text), &commit)
+	if !commit.Committed || commit.Subject != "docs: greet the world" || !strings.HasPrefix(s.git("rev-parse", "HEAD"), commit.Hash) {
+		t.Errorf("valid message: %+v", commit)
+	}
+	if got := s.git("log", "-1", "--format=%B"); got != "docs: greet the world" {
+		t.Errorf("committed message %q", got)
+	}
+}
+
+func TestCreateCommitAllowInvalid(t *testing.T) {
+	s := newSession(t)
+	s.write("README.md", "hello, world\n")
+	s.git("add", "README.md")
+
+	result := s.call(1, "create_commit", map[string]any{"message": "Updated the readme.", "allow_invalid": true})
+	if result.IsError {
+		t.Fatalf("allow_invalid: %s", result.Content[0].Text)
+	}
+	var commit struct {
+		Committed bool `json:"committed"`
+		Issues    []struct {
+			Rule string `json:"rule"`
+		} `json:"issues"`
+	}
+	json.Unmarshal([]byte(result.Content[0].Text), &commit)
+	if !commit.Committed || len(commit.Issues) == 0 {
+		t.Errorf("allow_invalid: %s", result.Content[0].Text)
+	}
+	if got := s.git("log", "-1", "--format=%s"); got != "Updated the readme." {
+		t.Errorf("committed subject %q", got)
+	}
+}
+
+func TestLintAndGenerate(t *testing.T) {
+	s := newSession(t)
+
+	result := s.call(1, "lint_commit_message", map[string]any{"message": "Updated the readme."})
+	if result.IsError || !strings.Contains(result.Content[0].Text, `"valid": false`) {
+		t.Errorf("lint: %+v", result)
+	}
+
+	result = s.call(2, "generate_commit_message", map[string]any{})
+	if result.IsError || !strings.Contains(result.Content[0].Text, `"message": "feat: generated"`) ||
+		!strings.Contains(result.Content[0].Text, `"valid": true`) {
+		t.Errorf("generate: %+v", result)
+	}
+}
@@ -0,0 +1,277 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/message"
+	"github.com/josinSbazin/AutoCommit/internal/rpc"
+)
+
+// maxHistory bounds get_commit_history
+const maxHistory = 100
+
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// toolResult is the result of tools/call. Failures are reported with
+// IsError so the model can see and react to them.
+type toolResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func object(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func toolList() []tool {
+	messageProp := map[string]any{"type": "string", "description": "Full commit message: subject, blank line, body and trailers"}
+	return []tool{
+		{
+			Name:        "get_staged_diff",
+			Description: "Get the changes staged for the next commit: changed files with line counts and the unified diff.",
+			InputSchema: object(map[string]any{}),
+		},
+		{
+			Name:        "generate_commit_message",
+			Description: "Generate a commit message for the staged changes with the repository's configured provider and style. Returns the message and any rule violations.",
+			InputSchema: object(map[string]any{
+				"regenerate": map[string]any{"type": "boolean", "description": "Skip the cached response and ask the provider again"},
+			}),
+		},
+		{
+			Name:        "lint_commit_message",
+			Description: "Check a commit message against the repository's rules from .autocommit.yml, such as Conventional Commits types, scopes and length limits.",
+			InputSchema: object(map[string]any{"message": messageProp}, "message"),
+		},
+		{
+			Name:        "create_commit",
+			Description: "Commit the staged changes with the given message. The message is linted first and rejected if it breaks a rule, unless allow_invalid is set.",
+			InputSchema: object(map[string]any{
+				"message":       messageProp,
+				"allow_invalid": map[string]any{"type": "boolean", "description": "Commit even if the message breaks a rule"},
+			}, "message"),
+		},
+		{
+			Name:        "get_commit_history",
+			Description: "Get recent non-merge commits, to match the repository's commit style.",
+			InputSchema: object(map[string]any{
+				"count": map[string]any{"type": "integer", "description": "Number of commits, 10 by default", "minimum": 1, "maximum": maxHistory},
+			}),
+		},
+	}
+}
+
+func (s *Server) callTool(ctx context.Context, name string, raw json.RawMessage) (any, error) {
+	var (
+		value any
+		err   error
+	)
+
+	switch name {
+	case "get_staged_diff":
+		value, err = s.stagedDiff()
+
+	case "generate_commit_message":
+		var args struct {
+			Regenerate bool `json:"regenerate"`
+		}
+		if err := decode(raw, &args); err != nil {
+			return nil, err
+		}
+		value, err = s.generateMessage(ctx, args.Regenerate)
+
+	case "lint_commit_message":
+		var args struct {
+			Message string `json:"message"`
+		}
+		if err := decode(raw, &args); err != nil {
+			return nil, err
+		}
+		value = s.lint(args.Message)
+
+	case "create_commit":
+		var args struct {
+			Message      string `json:"message"`
+			AllowInvalid bool   `json:"allow_invalid"`
+		}
+		if err := decode(raw, &args); err != nil {
+			return nil, err
+		}
+		value, err = s.createCommit(args.Message, args.AllowInvalid)
+
+	case "get_commit_history":
+		var args struct {
+			Count int `json:"count"`
+		}
+		if err := decode(raw, &args); err != nil {
+			return nil, err
+		}
+		value, err = s.history(args.Count)
+
+	default:
+		return nil, &rpc.Error{Code: rpc.CodeInvalidParams, Message: "unknown tool: " + name}
+	}
+
+	if err != nil {
+		return toolResult{Content: []textContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	if text, ok := value.(string); ok {
+		return toolResult{Content: []textContent{{Type: "text", Text: text}}}, nil
+	}
+
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return toolResult{Content: []textContent{{Type: "text", Text: string(data)}}}, nil
+}
+
+type fileInfo struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty"`
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+func (s *Server) stagedDiff() (any, error) {
+	diff, err := git.GetStagedDiff()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get diff: %w", err)
+	}
+	if diff.IsEmpty() {
+		return nil, fmt.Errorf("no staged changes. Stage files with git add first")
+	}
+
+	files := make([]fileInfo, 0, len(diff.Files))
+	for _, f := range diff.Files {
+		files = append(files, fileInfo{
+			Path:      f.Path,
+			OldPath:   f.OldPath,
+			Status:    f.Status,
+			Additions: f.Additions,
+			Deletions: f.Deletions,
+			Binary:    f.IsBinary,
+			Note:      f.Note,
+		})
+	}
+
+	return map[string]any{
+		"files":         files,
+		"files_changed": diff.Stats.FilesChanged,
+		"additions":     diff.Stats.Additions,
+		"deletions":     diff.Stats.Deletions,
+		"diff":          diff.RawDiff,
+	}, nil
+}
+
+func (s *Server) generateMessage(ctx context.Context, regenerate bool) (any, error) {
+	text, err := s.generate(ctx, s.dir, regenerate)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := message.Validate(s.config(), text)
+	return map[string]any{
+		"message": text,
+		"valid":   !message.HasErrors(issues),
+		"issues":  nonNil(issues),
+	}, nil
+}
+
+func (s *Server) lint(msg string) any {
+	issues := message.Validate(s.config(), msg)
+	return map[string]any{
+		"valid":  !message.HasErrors(issues),
+		"issues": nonNil(issues),
+	}
+}
+
+func (s *Server) createCommit(msg string, allowInvalid bool) (any, error) {
+	msg = strings.TrimSpace(msg)
+	issues := message.Validate(s.config(), msg)
+	if message.HasErrors(issues) && !allowInvalid {
+		var sb strings.Builder
+		sb.WriteString("commit message breaks the repository's rules:\n")
+		for _, i := range issues {
+			fmt.Fprintf(&sb, "- %s: %s (%s)\n", i.Severity, i.Message, i.Rule)
+		}
+		sb.WriteString("Fix the message, or set allow_invalid if the user asked for it.")
+		return nil, fmt.Errorf("%s", sb.String())
+	}
+
+	if !git.HasStagedChanges() {
+		return nil, fmt.Errorf("no staged changes. Stage files with git add first")
+	}
+	if err := git.CreateCommit(msg); err != nil {
+		return nil, err
+	}
+
+	result := map[string]any{"committed": true, "issues": nonNil(issues)}
+	if commits, err := git.GetCommitHistory(1); err == nil && len(commits) > 0 {
+		result["hash"] = commits[0].Hash
+		result["subject"] = commits[0].Subject
+	}
+	return result, nil
+}
+
+type commitInfo struct {
+	Hash    string `json:"hash"`
+	Author  string `json:"author"`
+	Subject string `json:"subject"`
+	Body    string `json:"body,omitempty"`
+}
+
+func (s *Server) history(count int) (any, error) {
+	if count <= 0 {
+		count = 10
+	}
+	count = min(count, maxHistory)
+
+	commits, err := git.GetCommitHistory(count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	result := make([]commitInfo, 0, len(commits))
+	for _, c := range commits {
+		result = append(result, commitInfo{Hash: c.Hash, Author: c.Author, Subject: c.Subject, Body: c.Body})
+	}
+	return result, nil
+}
+
+// config loads the rules for the repository
+func (s *Server) config() *config.Config {
+	cfg, err := config.LoadDir(s.dir)
+	if err != nil {
+		return config.Default()
+	}
+	return cfg
+}
+
+func nonNil(issues []message.Issue) []message.Issue {
+	if issues == nil {
+		return []message.Issue{}
+	}
+	return issues
+}
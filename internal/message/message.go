@@ -0,0 +1,108 @@
+package message
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Message is a commit message split into its parts
+type Message struct {
+	Subject  string
+	Body     string
+	Trailers []Trailer
+}
+
+// Trailer is a "Key: value" line at the end of a commit message
+type Trailer struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Header holds the parsed Conventional Commits header
+type Header struct {
+	Type        string
+	Scope       string
+	Breaking    bool
+	Description string
+}
+
+var (
+	trailerRe = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*|BREAKING CHANGE): (.+)$`)
+	headerRe  = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^()]*)\))?(!)?: (.*)$`)
+)
+
+// Parse splits a raw commit message into subject, body and trailers
+func Parse(raw string) Message {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	lines := strings.Split(strings.TrimSpace(raw), "\n")
+
+	var msg Message
+	if len(lines) == 0 {
+		return msg
+	}
+	msg.Subject = strings.TrimSpace(lines[0])
+
+	rest := lines[1:]
+	for len(rest) > 0 && strings.TrimSpace(rest[0]) == "" {
+		rest = rest[1:]
+	}
+
+	// Trailers are the last paragraph if every line in it looks like one
+	start := len(rest)
+	for start > 0 && strings.TrimSpace(rest[start-1]) != "" {
+		start--
+	}
+	if start < len(rest) && start > 0 {
+		var trailers []Trailer
+		for _, line := range rest[start:] {
+			m := trailerRe.FindStringSubmatch(strings.TrimSpace(line))
+			if m == nil {
+				trailers = nil
+				break
+			}
+			trailers = append(trailers, Trailer{Key: m[1], Value: m[2]})
+		}
+		if trailers != nil {
+			msg.Trailers = trailers
+			rest = rest[:start]
+		}
+	}
+
+	msg.Body = strings.TrimSpace(strings.Join(rest, "\n"))
+	return msg
+}
+
+// ParseHeader parses a Conventional Commits subject line.
+// It returns false if the subject does not follow the format.
+func ParseHeader(subject string) (Header, bool) {
+	m := headerRe.FindStringSubmatch(subject)
+	if m == nil {
+		return Header{}, false
+	}
+	return Header{
+		Type:        m[1],
+		Scope:       m[2],
+		Breaking:    m[3] == "!",
+		Description: m[4],
+	}, true
+}
+
+// String joins the message parts back together
+func (m Message) String() string {
+	var sb strings.Builder
+	sb.WriteString(m.Subject)
+	if m.Body != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(m.Body)
+	}
+	if len(m.Trailers) > 0 {
+		sb.WriteString("\n\n")
+		for i, t := range m.Trailers {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(t.Key + ": " + t.Value)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,96 @@
+package message
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+)
+
+// Severity levels for validation issues
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is a single validation problem
+type Issue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Line     int    `json:"line"`
+}
+
+// Validate checks a raw commit message against the configured rules
+func Validate(cfg *config.Config, raw string) []Issue {
+	var issues []Issue
+	add := func(rule, severity string, line int, format string, args ...any) {
+		issues = append(issues, Issue{
+			Rule:     rule,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+			Line:     line,
+		})
+	}
+
+	raw = strings.ReplaceAll(strings.TrimSpace(raw), "\r\n", "\n")
+	if raw == "" {
+		add("empty", SeverityError, 1, "commit message is empty")
+		return issues
+	}
+
+	lines := strings.Split(raw, "\n")
+	msg := Parse(raw)
+
+	if cfg.MaxSubjectLength > 0 && len([]rune(msg.Subject)) > cfg.MaxSubjectLength {
+		add("subject-max-length", SeverityError, 1,
+			"subject is %d characters, max is %d", len([]rune(msg.Subject)), cfg.MaxSubjectLength)
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		add("body-leading-blank", SeverityWarning, 2, "subject must be followed by a blank line")
+	}
+	if cfg.MaxBodyLength > 0 && len([]rune(msg.Body)) > cfg.MaxBodyLength {
+		add("body-max-length", SeverityWarning, 3,
+			"body is %d characters, max is %d", len([]rune(msg.Body)), cfg.MaxBodyLength)
+	}
+
+	if cfg.Style != "conventional" {
+		return issues
+	}
+
+	header, ok := ParseHeader(msg.Subject)
+	if !ok {
+		add("header-format", SeverityError, 1, "subject must match \"type(scope): description\"")
+		return issues
+	}
+
+	if len(cfg.Conventional.AllowedTypes) > 0 && !slices.Contains(cfg.Conventional.AllowedTypes, header.Type) {
+		add("type-enum", SeverityError, 1,
+			"type %q is not allowed (allowed: %s)", header.Type, strings.Join(cfg.Conventional.AllowedTypes, ", "))
+	}
+	if cfg.Conventional.RequireScope && header.Scope == "" {
+		add("scope-empty", SeverityError, 1, "scope is required")
+	}
+	if header.Scope != "" && len(cfg.Conventional.AllowedScopes) > 0 && !slices.Contains(cfg.Conventional.AllowedScopes, header.Scope) {
+		add("scope-enum", SeverityError, 1,
+			"scope %q is not allowed (allowed: %s)", header.Scope, strings.Join(cfg.Conventional.AllowedScopes, ", "))
+	}
+	if strings.TrimSpace(header.Description) == "" {
+		add("subject-empty", SeverityError, 1, "description is empty")
+	} else if strings.HasSuffix(header.Description, ".") {
+		add("subject-full-stop", SeverityWarning, 1, "subject must not end with a period")
+	}
+
+	return issues
+}
+
+// HasErrors reports whether any issue has error severity
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+// Package prefill stores commit messages generated in the background
+// before git commit runs, keyed by the hash of the staged tree
+package prefill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/provider"
+)
+
+const (
+	// Delay is how long a job waits for the index to settle before it
+	// sends a request, so a burst of git add calls costs one request
+	Delay = 2 * time.Second
+
+	// staleLock is how long a job may run before others ignore its lock
+	staleLock = 5 * time.Minute
+	// maxAge is how long unused entries are kept
+	maxAge = 24 * time.Hour
+
+	pollInterval = 100 * time.Millisecond
+)
+
+// ErrRunning is returned by Lock when another job works on the same tree
+var ErrRunning = errors.New("prefill already running")
+
+// Entry is a message generated for a staged tree
+type Entry struct {
+	Created  time.Time      `json:"created"`
+	Key      string         `json:"key"` // cache key of the prompt it answers
+	Provider string         `json:"provider"`
+	Model    string         `json:"model"`
+	Text     string         `json:"text"`
+	Usage    provider.Usage `json:"usage"`
+}
+
+// Dir returns the prefill directory inside the global config directory
+func Dir() string {
+	dir := config.GetGlobalConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "prefill")
+}
+
+// Get returns the entry for tree if it answers the prompt with key. A
+// different key means the history, branch or config changed since.
+func Get(tree, key string) (*Entry, bool) {
+	dir := Dir()
+	if dir == "" {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, tree+".json"))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Put stores the entry for tree and drops entries older than a day
+func Put(tree string, entry Entry) error {
+	dir := Dir()
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// Write and rename so a waiting hook never reads half an entry
+	tmp := filepath.Join(dir, tree+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, tree+".json")); err != nil {
+		return err
+	}
+
+	prune(dir)
+	return nil
+}
+
+// Lock claims tree for a job answering the prompt with key and returns
+// the function that releases it
+func Lock(tree, key string) (func(), error) {
+	dir := Dir()
+	if dir == "" {
+		return nil, fmt.Errorf("failed to determine config directory")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	path := lockPath(dir, tree)
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+		os.Remove(path)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if os.IsExist(err) {
+		return nil, ErrRunning
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(f, key)
+	f.Close()
+
+	return func() { os.Remove(path) }, nil
+}
+
+// Running reports whether a job is generating a message for tree that
+// answers the prompt with key. A job for another key, e.g. one started
+// before a branch switch, would not produce a usable entry.
+func Running(tree, key string) bool {
+	dir := Dir()
+	if dir == "" {
+		return false
+	}
+	path := lockPath(dir, tree)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > staleLock {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && strings.TrimSpace(string(data)) == key
+}
+
+// Wait blocks until no job is running for tree and key or ctx is done
+func Wait(ctx context.Context, tree, key string) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for Running(tree, key) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func lockPath(dir, tree string) string {
+	return filepath.Join(dir, tree+".lock")
+}
+
+func prune(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err == nil && time.Since(info.ModTime()) > maxAge {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
@@ -1,89 +1,271 @@
 package prompt
 
 import (
+	_ "embed"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"text/template"
 
 	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/deps"
 	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/semantic"
 )
 
+//go:embed templates/default.tmpl
+var defaultTemplate string
+
+// rawDiffLimit truncates the diff in the prompt
+const rawDiffLimit = 10000
+
 type Builder struct {
 	cfg *config.Config
+	// fallback is why the configured template was not used
+	fallback error
 }
 
 func NewBuilder(cfg *config.Config) *Builder {
 	return &Builder{cfg: cfg}
 }
 
-// Build constructs the prompt from diff and context
-func (b *Builder) Build(diff *git.DiffResult, history []git.Commit, branch string) string {
-	var sb strings.Builder
+// Data is what prompt templates can use. The prepared sections are empty
+// when their context setting is off or there is nothing to say.
+type Data struct {
+	Config  *config.Config
+	Diff    *git.DiffResult
+	Files   []git.FileChange
+	Stats   git.DiffStats
+	History []git.Commit
+	Branch  string
+	// Tickets are issue keys found in the branch name, e.g. PROJ-123 or #42
+	Tickets []string
+
+	// Built-in instructions for the configured style, language and format
+	Style    string
+	Language string
+	Output   string
+
+	Summary           string
+	Notes             []string
+	GoChanges         string
+	DependencyChanges string
+	// RawDiff is the diff without summarized dependency files, truncated
+	RawDiff string
+}
+
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"truncate": func(n int, s string) string {
+		if len(s) <= n {
+			return s
+		}
+		return s[:n] + "\n... (truncated)"
+	},
+}
 
-	// System instructions
-	sb.WriteString("You are an expert at writing clear, concise git commit messages.\n\n")
-
-	// Task
-	sb.WriteString("## Task\n")
-	sb.WriteString("Analyze the git diff below and generate a commit message.\n\n")
-
-	// Style instructions
-	sb.WriteString("## Style\n")
-	sb.WriteString(b.getStyleInstructions())
-	sb.WriteString("\n\n")
-
-	// Language
-	sb.WriteString("## Language\n")
-	sb.WriteString(b.getLanguageInstructions())
-	sb.WriteString("\n\n")
-
-	// Context
-	if b.cfg.Context.IncludeHistory && len(history) > 0 {
-		sb.WriteString("## Project Commit Style (for reference)\n")
-		sb.WriteString("Recent commits in this repository:\n")
-		for i, c := range history {
-			if i >= 5 {
-				break
+// Build renders the prompt templates with the diff and context. The
+// "system" and "user" blocks become the system prompt and the user
+// message; a custom template with a body of its own is sent as a single
+// user message instead. A custom template that can't be read, parsed or
+// rendered is replaced by the built-in one, see Fallback.
+func (b *Builder) Build(diff *git.DiffResult, history []git.Commit, branch string) (Prompt, error) {
+	data := b.data(diff, history, branch)
+
+	b.fallback = nil
+	if b.cfg.PromptTemplate != "" {
+		tmpl, err := b.template()
+		if err == nil {
+			var p Prompt
+			if p, err = build(tmpl, data, true); err == nil {
+				return p, nil
 			}
-			sb.WriteString(fmt.Sprintf("- %s\n", c.Subject))
 		}
-		sb.WriteString("\n")
+		b.fallback = err
 	}
+	return build(baseTemplate(), data, false)
+}
+
+// Fallback returns why the last Build used the built-in template instead
+// of the configured one, or nil
+func (b *Builder) Fallback() error {
+	return b.fallback
+}
 
-	if b.cfg.Context.IncludeBranch && branch != "" {
-		sb.WriteString("## Branch\n")
-		sb.WriteString(fmt.Sprintf("Current branch: %s\n\n", branch))
+func build(tmpl *template.Template, data Data, custom bool) (Prompt, error) {
+	if custom {
+		body, err := render(tmpl, data)
+		if err != nil {
+			return Prompt{}, err
+		}
+		if body != "" {
+			return User(body), nil
+		}
 	}
 
-	// Diff stats
-	if b.cfg.Context.IncludeDiffStats {
-		sb.WriteString("## Changes Summary\n")
-		sb.WriteString(diff.Summary())
-		sb.WriteString("\n")
+	system, err := render(tmpl.Lookup("system"), data)
+	if err != nil {
+		return Prompt{}, err
+	}
+	user, err := render(tmpl.Lookup("user"), data)
+	if err != nil {
+		return Prompt{}, err
 	}
+	return Prompt{System: system, Messages: []Message{{Role: RoleUser, Content: user}}}, nil
+}
+
+func render(tmpl *template.Template, data Data) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// Source returns the text of the template in use and where it comes from
+func (b *Builder) Source() (text, origin string, err error) {
+	if path := b.cfg.PromptTemplate; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read prompt template: %w", err)
+		}
+		return string(data), path, nil
+	}
+	return defaultTemplate, "built-in", nil
+}
 
-	// Full diff
-	sb.WriteString("## Git Diff\n")
-	sb.WriteString("```diff\n")
+// template parses the configured template into the same set as the
+// built-in one. A custom template can redefine the "system" and "user"
+// blocks, reuse the built-in ones as "default-system" and "default-user",
+// or wrap the whole built-in prompt with {{template "default" .}}.
+func (b *Builder) template() (*template.Template, error) {
+	base := baseTemplate()
+	if b.cfg.PromptTemplate == "" {
+		return base, nil
+	}
+
+	text, origin, err := b.Source()
+	if err != nil {
+		return nil, err
+	}
+	custom, err := base.New(filepath.Base(origin)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template: %w", err)
+	}
+	return custom, nil
+}
+
+func baseTemplate() *template.Template {
+	return template.Must(template.New("default").Funcs(funcs).Parse(defaultTemplate))
+}
+
+func (b *Builder) data(diff *git.DiffResult, history []git.Commit, branch string) Data {
+	data := Data{
+		Config:   b.cfg,
+		Diff:     diff,
+		Files:    diff.Files,
+		Stats:    diff.Stats,
+		History:  history,
+		Branch:   branch,
+		Tickets:  DetectTickets(branch),
+		Style:    b.getStyleInstructions(),
+		Language: b.getLanguageInstructions(),
+		Output:   b.getOutputInstructions(),
+		Summary:  diff.Summary(),
+		Notes:    diff.Notes(),
+	}
 
-	// Truncate if too long
+	if b.cfg.Context.IncludeGoSemantics {
+		if changes := semantic.AnalyzeGo(diff); len(changes) > 0 {
+			data.GoChanges = b.getSemanticSection(changes)
+		}
+	}
+
+	// Dependency changes replace noisy manifest and lockfile diffs
 	rawDiff := diff.RawDiff
-	if len(rawDiff) > 10000 {
-		rawDiff = rawDiff[:10000] + "\n... (truncated)"
+	if b.cfg.Context.SummarizeDeps {
+		if changes := deps.Analyze(diff); len(changes) > 0 {
+			data.DependencyChanges = b.getDepsSection(diff, changes)
+			rawDiff = filterDependencyDiffs(diff, changes)
+		}
+	}
+
+	if len(rawDiff) > rawDiffLimit {
+		rawDiff = rawDiff[:rawDiffLimit] + "\n... (truncated)"
+	}
+	data.RawDiff = rawDiff
+
+	return data
+}
+
+func (b *Builder) getDepsSection(diff *git.DiffResult, changes []deps.Change) string {
+	var sb strings.Builder
+
+	for _, c := range changes {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", c.Manifest, c.Describe()))
 	}
-	sb.WriteString(rawDiff)
-	sb.WriteString("\n```\n\n")
 
-	// Custom instructions
-	if b.cfg.Instructions != "" {
-		sb.WriteString("## Additional Instructions\n")
-		sb.WriteString(b.cfg.Instructions)
-		sb.WriteString("\n\n")
+	if deps.OnlyDependencies(diff.Files) && b.cfg.Style == "conventional" {
+		depsType := b.cfg.Conventional.DepsType
+		if depsType == "" {
+			depsType = "build"
+		}
+		sb.WriteString(fmt.Sprintf("\nThis commit only changes dependencies. Use \"%s(deps)\" as type and scope, ", depsType))
+		sb.WriteString("and use exactly the versions listed above.\n")
+	} else {
+		sb.WriteString("\nUse exactly the versions listed above when mentioning dependencies.\n")
 	}
 
-	// Output format
-	sb.WriteString("## Output Format\n")
-	sb.WriteString(b.getOutputInstructions())
+	return sb.String()
+}
+
+// filterDependencyDiffs rebuilds the raw diff without lockfiles and
+// manifests whose changes are fully described by the dependency summary
+func filterDependencyDiffs(diff *git.DiffResult, changes []deps.Change) string {
+	var sb strings.Builder
+
+	for _, f := range diff.Files {
+		if f.DiffChunk == "" {
+			// chunk could not be matched to the file, keep everything
+			return diff.RawDiff
+		}
+
+		switch {
+		case deps.IsLockfile(f.Path):
+			sb.WriteString(fmt.Sprintf("# %s: lockfile diff omitted\n", f.Path))
+		case deps.IsManifest(f.Path) && deps.OnlyDependencyLines(f.DiffChunk, deps.ForManifest(changes, f.Path)):
+			sb.WriteString(fmt.Sprintf("# %s: see Dependency Changes above\n", f.Path))
+		default:
+			sb.WriteString(f.DiffChunk)
+		}
+	}
+
+	return sb.String()
+}
+
+func (b *Builder) getSemanticSection(changes []semantic.Change) string {
+	var sb strings.Builder
+
+	for _, c := range changes {
+		sb.WriteString("- " + c.String())
+		if c.Breaking {
+			sb.WriteString(" (potentially breaking)")
+		}
+		sb.WriteString("\n")
+	}
+
+	if semantic.HasBreaking(changes) {
+		sb.WriteString("\nSome exported API changes may break importers. If the change is breaking, ")
+		if b.cfg.Style == "conventional" {
+			sb.WriteString("add ! after the type/scope (e.g. feat(api)!: ...) and a \"BREAKING CHANGE:\" footer.\n")
+		} else {
+			sb.WriteString("say so explicitly in the message.\n")
+		}
+	}
 
 	return sb.String()
 }
@@ -0,0 +1,231 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+)
+
+func testDiff() *git.DiffResult {
+	return &git.DiffResult{
+		Files: []git.FileChange{{Path: "login.go", Status: "modified", Additions: 1, Deletions: 1}},
+		Stats: git.DiffStats{FilesChanged: 1, Additions: 1, Deletions: 1},
+		RawDiff: "diff --git a/login.go b/login.go\n--- a/login.go\n+++ b/login.go\n" +
+			"@@ -1 +1 @@\n-old\n+new\n",
+	}
+}
+
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Context.IncludeGoSemantics = false
+	cfg.Context.SummarizeDeps = false
+	return cfg
+}
+
+func writeTemplate(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "prompt.tmpl")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuildDefault(t *testing.T) {
+	cfg := testConfig()
+	cfg.IncludeFooter = true
+	cfg.Instructions = "Mention the module."
+	history := []git.Commit{{Subject: "feat: add login"}}
+
+	b := NewBuilder(cfg)
+	p, err := b.Build(testDiff(), history, "feature/PROJ-7-login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Fallback() != nil {
+		t.Errorf("fallback without a custom template: %v", b.Fallback())
+	}
+	if len(p.Messages) != 1 || p.Messages[0].Role != RoleUser {
+		t.Fatalf("messages = %+v", p.Messages)
+	}
+
+	for _, want := range []string{"## Style", "Conventional Commits", "## Language", "## Additional Instructions\nMention the module.", "## Output Format"} {
+		if !strings.Contains(p.System, want) {
+			t.Errorf("system prompt lacks %q:\n%s", want, p.System)
+		}
+	}
+	user := p.Messages[0].Content
+	for _, want := range []string{"- feat: add login", "Current branch: feature/PROJ-7-login", "Reference PROJ-7 in a \"Refs:\" footer.", "```diff\ndiff --git a/login.go"} {
+		if !strings.Contains(user, want) {
+			t.Errorf("user message lacks %q:\n%s", want, user)
+		}
+	}
+	if strings.Contains(user, "## Style") {
+		t.Errorf("user message holds the system instructions:\n%s", user)
+	}
+}
+
+func TestBuildCustom(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		system   string // substring of the system prompt, empty for none
+		user     string // substring of the user message
+	}{
+		{
+			name:     "redefined user block",
+			template: `{{define "user"}}{{template "default-user" .}}` + "\n\nTickets: {{join .Tickets \", \"}}{{end}}",
+			system:   "## Output Format",
+			user:     "```\n\nTickets: PROJ-7",
+		},
+		{
+			name:     "redefined system block",
+			template: `{{define "system"}}Answer in {{upper .Config.Language}}.{{end}}`,
+			system:   "Answer in EN.",
+			user:     "## Git Diff",
+		},
+		{
+			name:     "body of its own",
+			template: "Files: {{range .Files}}{{.Path}} {{end}}on {{.Branch}}",
+			user:     "Files: login.go on feature/PROJ-7-login",
+		},
+		{
+			name:     "wrapped default",
+			template: `{{template "default" .}}` + "\nKeep it short.",
+			user:     "## Style",
+		},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		cfg.PromptTemplate = writeTemplate(t, tt.template)
+
+		b := NewBuilder(cfg)
+		p, err := b.Build(testDiff(), nil, "feature/PROJ-7-login")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if err := b.Fallback(); err != nil {
+			t.Errorf("%s: fell back: %v", tt.name, err)
+		}
+		if tt.system == "" && p.System != "" || !strings.Contains(p.System, tt.system) {
+			t.Errorf("%s: system prompt %q, want %q", tt.name, p.System, tt.system)
+		}
+		if len(p.Messages) != 1 || !strings.Contains(p.Messages[0].Content, tt.user) {
+			t.Errorf("%s: messages %+v, want %q", tt.name, p.Messages, tt.user)
+		}
+	}
+}
+
+func TestBuildFallback(t *testing.T) {
+	want, err := NewBuilder(testConfig()).Build(testDiff(), nil, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, path, err string
+	}{
+		{"missing", filepath.Join(t.TempDir(), "missing.tmpl"), "failed to read prompt template"},
+		{"unparsable", writeTemplate(t, `{{define "user"}}{{.Branch}`), "failed to parse prompt template"},
+		{"failing", writeTemplate(t, `{{define "user"}}{{.NoSuchField}}{{end}}`), "failed to render prompt template"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		cfg.PromptTemplate = tt.path
+
+		b := NewBuilder(cfg)
+		p, err := b.Build(testDiff(), nil, "main")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if err := b.Fallback(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: fallback %v, want %q", tt.name, err, tt.err)
+		}
+		if p.Text() != want.Text() {
+			t.Errorf("%s: got\n%s\nwant the built-in prompt\n%s", tt.name, p.Text(), want.Text())
+		}
+	}
+}
+
+func TestTemplateLookup(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", "")
+	for _, key := range []string{"AUTOCOMMIT_PROVIDER", "AUTOCOMMIT_MODEL", "AUTOCOMMIT_LANGUAGE", "AUTOCOMMIT_STYLE"} {
+		t.Setenv(key, "")
+	}
+
+	write := func(path, content string) {
+		t.Helper()
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	build := func(dir string) string {
+		t.Helper()
+		cfg, err := config.LoadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Context.IncludeGoSemantics = false
+		cfg.Context.SummarizeDeps = false
+		b := NewBuilder(cfg)
+		p, err := b.Build(testDiff(), nil, "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Fallback(); err != nil {
+			t.Fatal(err)
+		}
+		return p.Text()
+	}
+
+	dir := t.TempDir()
+	if text := build(dir); !strings.Contains(text, "## Git Diff") {
+		t.Errorf("no template: got\n%s", text)
+	}
+
+	write(filepath.Join(dir, config.ProjectPromptTemplate), "project template")
+	if text := build(dir); text != "project template" {
+		t.Errorf("%s: got %q", config.ProjectPromptTemplate, text)
+	}
+
+	write(filepath.Join(dir, "prompts", "team.tmpl"), "configured template")
+	write(filepath.Join(dir, ".autocommit.yml"), "prompt_template: prompts/team.tmpl\n")
+	if text := build(dir); text != "configured template" {
+		t.Errorf("prompt_template: got %q", text)
+	}
+}
+
+func TestDetectTickets(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   []string
+	}{
+		{"feature/PROJ-123-login", []string{"PROJ-123"}},
+		{"PROJ-1-and-OPS-22", []string{"PROJ-1", "OPS-22"}},
+		{"fix/AB2-9/AB2-9-retry", []string{"AB2-9"}},
+		{"fix/42-crash", []string{"#42"}},
+		{"issue-42", []string{"#42"}},
+		{"issues/42", []string{"#42"}},
+		{"gh-7_typo", []string{"#7"}},
+		{"#15", []string{"#15"}},
+		{"feature/PROJ-5-gh-7", []string{"PROJ-5"}},
+		{"main", nil},
+		{"release/v2", nil},
+		{"feature/oauth2-login", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got := DetectTickets(tt.branch)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("DetectTickets(%q) = %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+}
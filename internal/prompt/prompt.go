@@ -0,0 +1,56 @@
+package prompt
+
+import "strings"
+
+// Message roles
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one turn of a conversation with the model
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Prompt is a request to a provider: the system prompt with the standing
+// instructions, and the conversation so far, ending with a user message
+type Prompt struct {
+	System   string    `json:"system,omitempty"`
+	Messages []Message `json:"messages"`
+}
+
+// User returns a prompt of a single user message without a system prompt
+func User(text string) Prompt {
+	return Prompt{Messages: []Message{{Role: RoleUser, Content: text}}}
+}
+
+// FollowUp continues the conversation with the model's reply and a new
+// instruction, e.g. to repair or refine the reply. p is not modified.
+func (p Prompt) FollowUp(reply, instruction string) Prompt {
+	messages := make([]Message, 0, len(p.Messages)+2)
+	messages = append(messages, p.Messages...)
+	messages = append(messages,
+		Message{Role: RoleAssistant, Content: reply},
+		Message{Role: RoleUser, Content: instruction},
+	)
+	return Prompt{System: p.System, Messages: messages}
+}
+
+// Text flattens the prompt into a single text, for providers that take
+// one and for token estimates
+func (p Prompt) Text() string {
+	var parts []string
+	if p.System != "" {
+		parts = append(parts, p.System)
+	}
+	for _, m := range p.Messages {
+		if m.Role == RoleAssistant {
+			parts = append(parts, "Your previous answer:\n"+m.Content)
+			continue
+		}
+		parts = append(parts, m.Content)
+	}
+	return strings.Join(parts, "\n\n")
+}
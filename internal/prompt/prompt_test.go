@@ -0,0 +1,57 @@
+package prompt
+
+import "testing"
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt Prompt
+		want   string
+	}{
+		{"user only", User("the diff"), "the diff"},
+		{
+			name:   "system and user",
+			prompt: Prompt{System: "rules", Messages: []Message{{Role: RoleUser, Content: "the diff"}}},
+			want:   "rules\n\nthe diff",
+		},
+		{
+			name:   "follow-up",
+			prompt: Prompt{System: "rules", Messages: []Message{{Role: RoleUser, Content: "the diff"}}}.FollowUp("fix stuff", "Name the bug."),
+			want:   "rules\n\nthe diff\n\nYour previous answer:\nfix stuff\n\nName the bug.",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.prompt.Text(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFollowUp(t *testing.T) {
+	p := Prompt{System: "rules", Messages: make([]Message, 1, 4)}
+	p.Messages[0] = Message{Role: RoleUser, Content: "the diff"}
+
+	first := p.FollowUp("one", "again")
+	second := p.FollowUp("two", "once more")
+
+	if len(p.Messages) != 1 {
+		t.Errorf("original prompt changed: %+v", p.Messages)
+	}
+	want := []Message{
+		{Role: RoleUser, Content: "the diff"},
+		{Role: RoleAssistant, Content: "one"},
+		{Role: RoleUser, Content: "again"},
+	}
+	if first.System != "rules" || len(first.Messages) != len(want) {
+		t.Fatalf("got %+v", first)
+	}
+	for i := range want {
+		if first.Messages[i] != want[i] {
+			t.Errorf("message %d = %+v, want %+v", i, first.Messages[i], want[i])
+		}
+	}
+	// Follow-ups of the same prompt must not share a backing array
+	if first.Messages[1].Content != "one" || second.Messages[1].Content != "two" {
+		t.Errorf("follow-ups overwrite each other: %+v, %+v", first.Messages, second.Messages)
+	}
+}
@@ -0,0 +1,34 @@
+package prompt
+
+import (
+	"regexp"
+	"slices"
+)
+
+var (
+	// Jira-style keys: PROJ-123
+	ticketKeyRe = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-[0-9]+\b`)
+	// GitHub and GitLab issue numbers: 42-fix, issue-42, issues/42, gh-42, #42
+	issueNumberRe = regexp.MustCompile(`(?i)(?:^|/)(?:(?:issues?|gh)[-/_]?|#)?([0-9]+)(?:[-_]|$)`)
+)
+
+// DetectTickets finds issue references in a branch name, such as
+// feature/PROJ-123-login or fix/42-crash
+func DetectTickets(branch string) []string {
+	var tickets []string
+	for _, key := range ticketKeyRe.FindAllString(branch, -1) {
+		if !slices.Contains(tickets, key) {
+			tickets = append(tickets, key)
+		}
+	}
+	if len(tickets) > 0 {
+		return tickets
+	}
+
+	for _, m := range issueNumberRe.FindAllStringSubmatch(branch, -1) {
+		if ref := "#" + m[1]; !slices.Contains(tickets, ref) {
+			tickets = append(tickets, ref)
+		}
+	}
+	return tickets
+}
@@ -10,11 +10,13 @@ import (
 	"os"
 
 	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
 )
 
 type AnthropicProvider struct {
 	apiKey string
 	model  string
+	client *http.Client
 }
 
 func NewAnthropicProvider(cfg *config.Config) (*AnthropicProvider, error) {
@@ -28,13 +30,21 @@ func NewAnthropicProvider(cfg *config.Config) (*AnthropicProvider, error) {
 		model = "claude-sonnet-4-20250514"
 	}
 
-	return &AnthropicProvider{apiKey: apiKey, model: model}, nil
+	return &AnthropicProvider{
+		apiKey: apiKey,
+		model:  model,
+		client: newHTTPClient(cfg, "anthropic"),
+	}, nil
 }
 
 func (p *AnthropicProvider) Name() string {
 	return "anthropic"
 }
 
+func (p *AnthropicProvider) Model() string {
+	return p.model
+}
+
 func (p *AnthropicProvider) Validate() error {
 	if p.apiKey == "" {
 		return fmt.Errorf("API key not configured")
@@ -42,42 +52,47 @@ func (p *AnthropicProvider) Validate() error {
 	return nil
 }
 
-func (p *AnthropicProvider) Generate(ctx context.Context, prompt string) (string, error) {
+func (p *AnthropicProvider) Generate(ctx context.Context, text string) (*Result, error) {
+	return p.Chat(ctx, prompt.User(text))
+}
+
+func (p *AnthropicProvider) Chat(ctx context.Context, conversation prompt.Prompt) (*Result, error) {
 	reqBody := map[string]any{
 		"model":      p.model,
 		"max_tokens": 1024,
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
+		"messages":   chatMessages(conversation, "content", false),
+	}
+	if conversation.System != "" {
+		reqBody["system"] = conversation.System
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewReader(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("x-api-key", p.apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
-	resp, err := httpClient.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
@@ -85,15 +100,25 @@ func (p *AnthropicProvider) Generate(ctx context.Context, prompt string) (string
 			Type string `json:"type"`
 			Text string `json:"text"`
 		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if len(result.Content) == 0 {
-		return "", fmt.Errorf("empty response from API")
+		return nil, fmt.Errorf("empty response from API")
 	}
 
-	return result.Content[0].Text, nil
+	return &Result{
+		Text: result.Content[0].Text,
+		Usage: Usage{
+			PromptTokens:     result.Usage.InputTokens,
+			CompletionTokens: result.Usage.OutputTokens,
+		},
+	}, nil
 }
@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
+)
+
+// Plugin executables are found on PATH by this prefix, so provider
+// "exec:my-llm" or just "my-llm" runs autocommit-provider-my-llm
+const (
+	ExecPrefix   = "exec:"
+	PluginPrefix = "autocommit-provider-"
+)
+
+// pluginProtocolVersion is sent with every request so plugins can reject
+// versions they don't understand
+const pluginProtocolVersion = 1
+
+// ExecProvider runs an external plugin executable. The request is written
+// to its stdin as JSON and the response is read from stdout once it exits,
+// either as a single JSON object or as NDJSON deltas.
+type ExecProvider struct {
+	name   string
+	path   string
+	model  string
+	params map[string]any
+	cfg    *config.Config
+}
+
+// pluginRequest is the JSON written to the plugin's stdin. Prompt is the
+// whole conversation as one text, for plugins that don't read System and
+// Messages.
+type pluginRequest struct {
+	Version  int              `json:"version"`
+	Prompt   string           `json:"prompt"`
+	System   string           `json:"system,omitempty"`
+	Messages []prompt.Message `json:"messages"`
+	Model    string           `json:"model,omitempty"`
+	Params   map[string]any   `json:"params"`
+}
+
+// pluginResponse is one JSON value read from the plugin's stdout. A
+// plain response sets text; NDJSON output sends delta lines and may
+// report usage on the last one.
+type pluginResponse struct {
+	Text  string `json:"text"`
+	Delta string `json:"delta"`
+	Model string `json:"model"`
+	Usage *Usage `json:"usage"`
+	Error string `json:"error"`
+}
+
+func NewExecProvider(cfg *config.Config) (*ExecProvider, error) {
+	name := strings.TrimPrefix(cfg.Provider, ExecPrefix)
+	if name == "" {
+		return nil, fmt.Errorf("exec provider needs a plugin name, e.g. exec:my-llm")
+	}
+
+	path, err := findPlugin(name)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]any{
+		"temperature": 0.3,
+		"max_tokens":  1024,
+	}
+	for k, v := range cfg.PluginParams {
+		params[k] = v
+	}
+
+	return &ExecProvider{
+		name:   name,
+		path:   path,
+		model:  cfg.Model,
+		params: params,
+		cfg:    cfg,
+	}, nil
+}
+
+func (p *ExecProvider) Name() string {
+	return ExecPrefix + p.name
+}
+
+func (p *ExecProvider) Model() string {
+	if p.model == "" {
+		return "default"
+	}
+	return p.model
+}
+
+func (p *ExecProvider) Validate() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("plugin not found: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("plugin %s is a directory", p.path)
+	}
+	return nil
+}
+
+func (p *ExecProvider) Generate(ctx context.Context, text string) (*Result, error) {
+	return p.Chat(ctx, prompt.User(text))
+}
+
+func (p *ExecProvider) Chat(ctx context.Context, conversation prompt.Prompt) (*Result, error) {
+	if _, response := p.cfg.Timeouts.ForProvider(p.Name()); response > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, response)
+		defer cancel()
+	}
+
+	reqBody, err := json.Marshal(pluginRequest{
+		Version:  pluginProtocolVersion,
+		Prompt:   conversation.Text(),
+		System:   conversation.System,
+		Messages: conversation.Messages,
+		Model:    p.model,
+		Params:   p.params,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, p.path)
+	cmd.Stdin = bytes.NewReader(reqBody)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("plugin %s failed: %s", p.name, msg)
+	}
+
+	return parsePluginOutput(&stdout)
+}
+
+// parsePluginOutput reads a single response or an NDJSON stream
+func parsePluginOutput(r io.Reader) (*Result, error) {
+	result := &Result{}
+	var text strings.Builder
+	seen := false
+
+	dec := json.NewDecoder(r)
+	for {
+		var resp pluginResponse
+		err := dec.Decode(&resp)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse plugin response: %w", err)
+		}
+		seen = true
+
+		if resp.Error != "" {
+			return nil, fmt.Errorf("plugin error: %s", resp.Error)
+		}
+		if resp.Text != "" {
+			text.Reset()
+			text.WriteString(resp.Text)
+		}
+		text.WriteString(resp.Delta)
+		if resp.Usage != nil {
+			result.Usage = *resp.Usage
+		}
+	}
+
+	if !seen {
+		return nil, fmt.Errorf("plugin returned no response")
+	}
+	result.Text = text.String()
+	return result, nil
+}
+
+// findPlugin resolves a plugin name to an executable: a path is used as
+// is, otherwise only autocommit-provider-<name> on PATH. Paths can only
+// come from the global config, see config.restrictProject.
+func findPlugin(name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return name, nil
+	}
+	if path, err := exec.LookPath(PluginPrefix + name); err == nil {
+		return path, nil
+	}
+	return "", fmt.Errorf("plugin %s not found: install %s%s on PATH", name, PluginPrefix, name)
+}
+
+// Plugins lists the names of autocommit-provider-* executables on PATH
+func Plugins() []string {
+	seen := map[string]bool{}
+	var names []string
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		matches, _ := filepath.Glob(filepath.Join(dir, PluginPrefix+"*"))
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+				continue
+			}
+			name := strings.TrimPrefix(filepath.Base(m), PluginPrefix)
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
+)
+
+func TestParsePluginOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		text   string
+		usage  Usage
+		err    string
+	}{
+		{
+			name:   "single response",
+			output: `{"text": "feat: add parser", "usage": {"prompt_tokens": 10, "completion_tokens": 3}}`,
+			text:   "feat: add parser",
+			usage:  Usage{PromptTokens: 10, CompletionTokens: 3},
+		},
+		{
+			name:   "deltas with usage last",
+			output: "{\"delta\": \"feat: \"}\n{\"delta\": \"add parser\"}\n{\"usage\": {\"prompt_tokens\": 7, \"completion_tokens\": 2}}\n",
+			text:   "feat: add parser",
+			usage:  Usage{PromptTokens: 7, CompletionTokens: 2},
+		},
+		{
+			name:   "text replaces earlier deltas",
+			output: "{\"delta\": \"draft\"}\n{\"text\": \"fix: final\"}\n{\"delta\": \" message\"}\n",
+			text:   "fix: final message",
+		},
+		{
+			name:   "error line",
+			output: "{\"delta\": \"feat\"}\n{\"error\": \"rate limited\"}\n",
+			err:    "plugin error: rate limited",
+		},
+		{
+			name:   "empty output",
+			output: "",
+			err:    "plugin returned no response",
+		},
+		{
+			name:   "not JSON",
+			output: "feat: add parser\n",
+			err:    "failed to parse plugin response",
+		},
+	}
+	for _, tt := range tests {
+		result, err := parsePluginOutput(strings.NewReader(tt.output))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if result.Text != tt.text || result.Usage != tt.usage {
+			t.Errorf("%s: got %q %+v, want %q %+v", tt.name, result.Text, result.Usage, tt.text, tt.usage)
+		}
+	}
+}
+
+// plugin writes an autocommit-provider-<name> script that saves its
+// request next to itself, into a directory put first on PATH
+func plugin(t *testing.T, name, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts need sh")
+	}
+	dir := t.TempDir()
+	body := "#!/bin/sh\ncat > \"$(dirname \"$0\")/request.json\"\n" + script
+	if err := os.WriteFile(filepath.Join(dir, PluginPrefix+name), []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestExecPluginFromPath(t *testing.T) {
+	dir := plugin(t, "x", `echo '{"delta": "feat: "}'
+echo '{"delta": "add parser", "usage": {"prompt_tokens": 5, "completion_tokens": 2}}'
+`)
+
+	for _, name := range []string{"x", "exec:x"} {
+		cfg := config.Default()
+		cfg.Provider = name
+		cfg.Model = "tiny"
+		cfg.PluginParams = map[string]any{"temperature": 0.1}
+		prov, err := Get(cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if prov.Name() != "exec:x" {
+			t.Errorf("%s: name = %q", name, prov.Name())
+		}
+
+		conversation := prompt.Prompt{System: "rules", Messages: []prompt.Message{{Role: prompt.RoleUser, Content: "diff"}}}
+		result, err := Send(context.Background(), prov, conversation)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if result.Text != "feat: add parser" || result.Usage.Total() != 7 {
+			t.Errorf("%s: result = %+v", name, result)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req map[string]any
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+	params, _ := req["params"].(map[string]any)
+	switch {
+	case req["version"] != float64(1), req["system"] != "rules", req["model"] != "tiny":
+		t.Errorf("request = %s", data)
+	case params["temperature"] != 0.1 || params["max_tokens"] != float64(1024):
+		t.Errorf("params = %v", params)
+	case !strings.Contains(req["prompt"].(string), "rules") || !strings.Contains(req["prompt"].(string), "diff"):
+		t.Errorf("prompt = %q", req["prompt"])
+	}
+	if _, ok := req["stream"]; ok {
+		t.Error("request still has stream")
+	}
+}
+
+func TestExecPluginFailure(t *testing.T) {
+	plugin(t, "broken", "echo 'no key configured' >&2\nexit 3\n")
+	cfg := config.Default()
+	cfg.Provider = "exec:broken"
+	prov, err := Get(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prov.Generate(context.Background(), "diff"); err == nil || !strings.Contains(err.Error(), "no key configured") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestFindPlugin(t *testing.T) {
+	dir := plugin(t, "x", "")
+
+	if path, err := findPlugin("x"); err != nil || path != filepath.Join(dir, PluginPrefix+"x") {
+		t.Errorf("findPlugin(x) = %q, %v", path, err)
+	}
+	if path, err := findPlugin("/opt/llm/run"); err != nil || path != "/opt/llm/run" {
+		t.Errorf("path = %q, %v", path, err)
+	}
+	// A bare name never resolves to another executable on PATH
+	os.WriteFile(filepath.Join(dir, "y"), []byte("#!/bin/sh\n"), 0755)
+	if _, err := findPlugin("y"); err == nil || !strings.Contains(err.Error(), PluginPrefix+"y") {
+		t.Errorf("findPlugin(y) = %v", err)
+	}
+	if got := Plugins(); !slices.Contains(got, "x") || slices.Contains(got, "y") {
+		t.Errorf("Plugins() = %q", got)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
 )
 
 type GigaChatProvider struct {
@@ -56,17 +57,16 @@ func NewGigaChatProvider(cfg *config.Config) (*GigaChatProvider, error) {
 		model = "GigaChat"
 	}
 
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
+	client := newHTTPClient(cfg, "gigachat")
+	client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: true,
 	}
 
 	return &GigaChatProvider{
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		model:        model,
-		client:       &http.Client{Transport: transport, Timeout: 60 * time.Second},
+		client:       client,
 	}, nil
 }
 
@@ -74,6 +74,10 @@ func (p *GigaChatProvider) Name() string {
 	return "gigachat"
 }
 
+func (p *GigaChatProvider) Model() string {
+	return p.model
+}
+
 func (p *GigaChatProvider) Validate() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -135,30 +139,32 @@ func (p *GigaChatProvider) authorize(ctx context.Context) error {
 	return nil
 }
 
-func (p *GigaChatProvider) Generate(ctx context.Context, prompt string) (string, error) {
+func (p *GigaChatProvider) Generate(ctx context.Context, text string) (*Result, error) {
+	return p.Chat(ctx, prompt.User(text))
+}
+
+func (p *GigaChatProvider) Chat(ctx context.Context, conversation prompt.Prompt) (*Result, error) {
 	if err := p.authorize(ctx); err != nil {
-		return "", fmt.Errorf("authorization failed: %w", err)
+		return nil, fmt.Errorf("authorization failed: %w", err)
 	}
 
 	reqBody := map[string]any{
-		"model": p.model,
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
+		"model":       p.model,
+		"messages":    chatMessages(conversation, "content", true),
 		"temperature": 0.3,
 		"max_tokens":  1024,
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST",
 		"https://gigachat.devices.sberbank.ru/api/v1/chat/completions",
 		bytes.NewReader(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -166,17 +172,17 @@ func (p *GigaChatProvider) Generate(ctx context.Context, prompt string) (string,
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
@@ -185,15 +191,25 @@ func (p *GigaChatProvider) Generate(ctx context.Context, prompt string) (string,
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("empty response from API")
+		return nil, fmt.Errorf("empty response from API")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	return &Result{
+		Text: result.Choices[0].Message.Content,
+		Usage: Usage{
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+		},
+	}, nil
 }
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/heuristic"
+)
+
+// HeuristicProvider writes conventional commits from diff analysis alone.
+// It never touches the network and ignores the prompt, describing the diff
+// set on the context with WithDiff.
+type HeuristicProvider struct {
+	cfg *config.Config
+}
+
+func NewHeuristicProvider(cfg *config.Config) (*HeuristicProvider, error) {
+	return &HeuristicProvider{cfg: cfg}, nil
+}
+
+func (p *HeuristicProvider) Name() string {
+	return "heuristic"
+}
+
+func (p *HeuristicProvider) Model() string {
+	return "rules"
+}
+
+func (p *HeuristicProvider) Validate() error {
+	return nil
+}
+
+func (p *HeuristicProvider) Generate(ctx context.Context, prompt string) (*Result, error) {
+	diff := DiffFromContext(ctx)
+	if diff == nil {
+		return nil, fmt.Errorf("no diff to describe: the heuristic provider needs the diff on the context")
+	}
+	if diff.IsEmpty() {
+		return nil, fmt.Errorf("no staged changes")
+	}
+
+	return &Result{Text: heuristic.Generate(p.cfg, diff)}, nil
+}
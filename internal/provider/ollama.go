@@ -7,30 +7,28 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
 )
 
 type OllamaProvider struct {
-	host  string
-	model string
+	host   string
+	model  string
+	client *http.Client
 }
 
 func NewOllamaProvider(cfg *config.Config) (*OllamaProvider, error) {
-	host := os.Getenv("OLLAMA_HOST")
-	if host == "" {
-		host = "http://localhost:11434"
-	}
-
 	model := cfg.Model
 	if model == "" {
 		model = "llama3.1"
 	}
 
 	return &OllamaProvider{
-		host:  host,
-		model: model,
+		host:   ollamaHost(),
+		model:  model,
+		client: newHTTPClient(cfg, "ollama"),
 	}, nil
 }
 
@@ -38,8 +36,20 @@ func (p *OllamaProvider) Name() string {
 	return "ollama"
 }
 
+func (p *OllamaProvider) Model() string {
+	return p.model
+}
+
 func (p *OllamaProvider) Validate() error {
-	resp, err := httpClient.Get(p.host + "/api/tags")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.host+"/api/tags", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("cannot connect to Ollama at %s: %w", p.host, err)
 	}
@@ -52,50 +62,82 @@ func (p *OllamaProvider) Validate() error {
 	return nil
 }
 
-func (p *OllamaProvider) Generate(ctx context.Context, prompt string) (string, error) {
+func (p *OllamaProvider) Generate(ctx context.Context, text string) (*Result, error) {
+	return p.Chat(ctx, prompt.User(text))
+}
+
+func (p *OllamaProvider) Chat(ctx context.Context, conversation prompt.Prompt) (*Result, error) {
 	reqBody := map[string]any{
 		"model":  p.model,
-		"prompt": prompt,
 		"stream": false,
 		"options": map[string]any{
 			"temperature": 0.3,
 		},
 	}
 
+	// A single question goes to the generate endpoint with its system
+	// prompt; follow-ups need the chat endpoint, which takes the history
+	path := "/api/generate"
+	if len(conversation.Messages) == 1 {
+		reqBody["prompt"] = conversation.Messages[0].Content
+		if conversation.System != "" {
+			reqBody["system"] = conversation.System
+		}
+	} else {
+		path = "/api/chat"
+		reqBody["messages"] = chatMessages(conversation, "content", true)
+	}
+
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", p.host+"/api/generate", bytes.NewReader(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", p.host+path, bytes.NewReader(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
 		Response string `json:"response"`
+		Message  struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		PromptEvalCount int `json:"prompt_eval_count"`
+		EvalCount       int `json:"eval_count"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	return result.Response, nil
+	text := result.Response
+	if path == "/api/chat" {
+		text = result.Message.Content
+	}
+
+	return &Result{
+		Text: text,
+		Usage: Usage{
+			PromptTokens:     result.PromptEvalCount,
+			CompletionTokens: result.EvalCount,
+		},
+	}, nil
 }
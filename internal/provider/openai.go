@@ -8,17 +8,16 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"time"
 
 	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
 )
 
-var httpClient = &http.Client{Timeout: 60 * time.Second}
-
 type OpenAIProvider struct {
 	apiKey   string
 	model    string
 	endpoint string
+	client   *http.Client
 }
 
 func NewOpenAIProvider(cfg *config.Config) (*OpenAIProvider, error) {
@@ -36,6 +35,7 @@ func NewOpenAIProvider(cfg *config.Config) (*OpenAIProvider, error) {
 		apiKey:   apiKey,
 		model:    model,
 		endpoint: "https://api.openai.com/v1/chat/completions",
+		client:   newHTTPClient(cfg, "openai"),
 	}, nil
 }
 
@@ -43,6 +43,10 @@ func (p *OpenAIProvider) Name() string {
 	return "openai"
 }
 
+func (p *OpenAIProvider) Model() string {
+	return p.model
+}
+
 func (p *OpenAIProvider) Validate() error {
 	if p.apiKey == "" {
 		return fmt.Errorf("API key not configured")
@@ -50,42 +54,44 @@ func (p *OpenAIProvider) Validate() error {
 	return nil
 }
 
-func (p *OpenAIProvider) Generate(ctx context.Context, prompt string) (string, error) {
+func (p *OpenAIProvider) Generate(ctx context.Context, text string) (*Result, error) {
+	return p.Chat(ctx, prompt.User(text))
+}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, conversation prompt.Prompt) (*Result, error) {
 	reqBody := map[string]any{
-		"model": p.model,
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
+		"model":       p.model,
+		"messages":    chatMessages(conversation, "content", true),
 		"max_tokens":  1024,
 		"temperature": 0.3,
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint, bytes.NewReader(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+p.apiKey)
 
-	resp, err := httpClient.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
@@ -94,15 +100,25 @@ func (p *OpenAIProvider) Generate(ctx context.Context, prompt string) (string, e
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("empty response from API")
+		return nil, fmt.Errorf("empty response from API")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	return &Result{
+		Text: result.Choices[0].Message.Content,
+		Usage: Usage{
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+		},
+	}, nil
 }
@@ -10,12 +10,14 @@ import (
 	"os"
 
 	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
 )
 
 type OpenAICompatibleProvider struct {
 	apiKey   string
 	endpoint string
 	model    string
+	client   *http.Client
 }
 
 func NewOpenAICompatibleProvider(cfg *config.Config) (*OpenAICompatibleProvider, error) {
@@ -65,6 +67,7 @@ func NewOpenAICompatibleProvider(cfg *config.Config) (*OpenAICompatibleProvider,
 		apiKey:   apiKey,
 		endpoint: endpoint,
 		model:    model,
+		client:   newHTTPClient(cfg, "openai-compatible"),
 	}, nil
 }
 
@@ -72,28 +75,34 @@ func (p *OpenAICompatibleProvider) Name() string {
 	return "openai-compatible"
 }
 
+func (p *OpenAICompatibleProvider) Model() string {
+	return p.model
+}
+
 func (p *OpenAICompatibleProvider) Validate() error {
 	return nil
 }
 
-func (p *OpenAICompatibleProvider) Generate(ctx context.Context, prompt string) (string, error) {
+func (p *OpenAICompatibleProvider) Generate(ctx context.Context, text string) (*Result, error) {
+	return p.Chat(ctx, prompt.User(text))
+}
+
+func (p *OpenAICompatibleProvider) Chat(ctx context.Context, conversation prompt.Prompt) (*Result, error) {
 	reqBody := map[string]any{
-		"model": p.model,
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
+		"model":       p.model,
+		"messages":    chatMessages(conversation, "content", true),
 		"max_tokens":  1024,
 		"temperature": 0.3,
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", p.endpoint, bytes.NewReader(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -101,19 +110,19 @@ func (p *OpenAICompatibleProvider) Generate(ctx context.Context, prompt string)
 		req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	}
 
-	resp, err := httpClient.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
@@ -122,17 +131,27 @@ func (p *OpenAICompatibleProvider) Generate(ctx context.Context, prompt string)
 				Content string `json:"content"`
 			} `json:"message"`
 		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("empty response from API")
+		return nil, fmt.Errorf("empty response from API")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	return &Result{
+		Text: result.Choices[0].Message.Content,
+		Usage: Usage{
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+		},
+	}, nil
 }
 
 func hasSubstring(s, substr string) bool {
@@ -3,19 +3,115 @@ package provider
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
 )
 
+// Usage holds token counts reported by the provider
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Total returns the sum of prompt and completion tokens
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Result is the outcome of a single generation request
+type Result struct {
+	Text  string
+	Usage Usage
+}
+
 type Provider interface {
-	Generate(ctx context.Context, prompt string) (string, error)
+	Generate(ctx context.Context, prompt string) (*Result, error)
 	Name() string
+	Model() string
 	Validate() error
 }
 
+// ChatProvider takes the system prompt and the conversation separately
+// instead of as one text. The built-in API providers implement it.
+type ChatProvider interface {
+	Provider
+	Chat(ctx context.Context, conversation prompt.Prompt) (*Result, error)
+}
+
+// Send sends a prompt to prov, flattened to a single text if prov is not
+// a ChatProvider
+func Send(ctx context.Context, prov Provider, p prompt.Prompt) (*Result, error) {
+	if chat, ok := prov.(ChatProvider); ok {
+		return chat.Chat(ctx, p)
+	}
+	return prov.Generate(ctx, p.Text())
+}
+
+// chatMessages converts a conversation to role and content pairs, led by
+// the system prompt as a "system" message if withSystem is set. YandexGPT
+// calls the content field "text".
+func chatMessages(conversation prompt.Prompt, contentKey string, withSystem bool) []map[string]string {
+	var messages []map[string]string
+	if withSystem && conversation.System != "" {
+		messages = append(messages, map[string]string{"role": "system", contentKey: conversation.System})
+	}
+	for _, m := range conversation.Messages {
+		messages = append(messages, map[string]string{"role": m.Role, contentKey: m.Content})
+	}
+	return messages
+}
+
+// Factory creates a provider from configuration
+type Factory func(cfg *config.Config) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a custom provider available under name. Built-in
+// provider names take precedence.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+func lookup(name string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	f, ok := registry[name]
+	return f, ok
+}
+
+type diffKey struct{}
+
+// WithDiff returns a context carrying the diff being described, for
+// providers that work from the diff rather than the prompt
+func WithDiff(ctx context.Context, diff *git.DiffResult) context.Context {
+	return context.WithValue(ctx, diffKey{}, diff)
+}
+
+// DiffFromContext returns the diff set by WithDiff, or nil
+func DiffFromContext(ctx context.Context) *git.DiffResult {
+	diff, _ := ctx.Value(diffKey{}).(*git.DiffResult)
+	return diff
+}
+
 func Get(cfg *config.Config) (Provider, error) {
+	if strings.HasPrefix(cfg.Provider, ExecPrefix) {
+		return NewExecProvider(cfg)
+	}
+
 	switch cfg.Provider {
 	case "anthropic", "claude":
 		return NewAnthropicProvider(cfg)
@@ -29,9 +125,20 @@ func Get(cfg *config.Config) (Provider, error) {
 		return NewYandexGPTProvider(cfg)
 	case "openai-compatible":
 		return NewOpenAICompatibleProvider(cfg)
+	case "heuristic", "offline":
+		return NewHeuristicProvider(cfg)
 	case "":
 		return AutoDetect(cfg)
 	default:
+		if factory, ok := lookup(cfg.Provider); ok {
+			return factory(cfg)
+		}
+		if strings.ContainsAny(cfg.Provider, `/\`) {
+			return nil, fmt.Errorf("unknown provider: %s (use exec:%s to run a binary)", cfg.Provider, cfg.Provider)
+		}
+		if _, err := exec.LookPath(PluginPrefix + cfg.Provider); err == nil {
+			return NewExecProvider(cfg)
+		}
 		return nil, fmt.Errorf("unknown provider: %s", cfg.Provider)
 	}
 }
@@ -64,15 +171,42 @@ func AutoDetect(cfg *config.Config) (Provider, error) {
 	return nil, fmt.Errorf("no provider configured. Run 'autocommit init' or set API key")
 }
 
+const ollamaProbeTimeout = 2 * time.Second
+
+// isOllamaAvailable probes the local Ollama server with a short timeout
+// so that autodetection never hangs
 func isOllamaAvailable() bool {
-	host := os.Getenv("OLLAMA_HOST")
-	if host == "" {
-		host = "http://localhost:11434"
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ollamaHost()+"/api/tags", nil)
+	if err != nil {
+		return false
 	}
-	resp, err := http.Get(host + "/api/tags")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return false
 	}
 	resp.Body.Close()
 	return resp.StatusCode == http.StatusOK
 }
+
+func ollamaHost() string {
+	if host := os.Getenv("OLLAMA_HOST"); host != "" {
+		return host
+	}
+	return "http://localhost:11434"
+}
+
+// newHTTPClient builds a client with the configured timeouts for a provider
+func newHTTPClient(cfg *config.Config, name string) *http.Client {
+	connect, response := cfg.Timeouts.ForProvider(name)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if connect > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: connect}).DialContext
+		transport.TLSHandshakeTimeout = connect
+	}
+
+	return &http.Client{Transport: transport, Timeout: response}
+}
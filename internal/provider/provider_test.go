@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
+)
+
+// textProvider records the text it is asked to complete
+type textProvider struct {
+	text string
+}
+
+func (p *textProvider) Generate(ctx context.Context, text string) (*Result, error) {
+	p.text = text
+	return &Result{Text: "generated"}, nil
+}
+
+func (p *textProvider) Name() string    { return "text" }
+func (p *textProvider) Model() string   { return "test" }
+func (p *textProvider) Validate() error { return nil }
+
+// chatProvider records the conversation it is sent
+type chatProvider struct {
+	textProvider
+	conversation *prompt.Prompt
+}
+
+func (p *chatProvider) Chat(ctx context.Context, conversation prompt.Prompt) (*Result, error) {
+	p.conversation = &conversation
+	return &Result{Text: "chatted"}, nil
+}
+
+func TestSend(t *testing.T) {
+	conversation := prompt.Prompt{
+		System:   "Write commit messages.",
+		Messages: []prompt.Message{{Role: prompt.RoleUser, Content: "the diff"}},
+	}.FollowUp("fix stuff", "Name the bug.")
+
+	chat := &chatProvider{}
+	result, err := Send(context.Background(), chat, conversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Text != "chatted" {
+		t.Errorf("chat provider answered %q", result.Text)
+	}
+	if chat.text != "" {
+		t.Errorf("chat provider got a flattened prompt: %q", chat.text)
+	}
+	if chat.conversation == nil || chat.conversation.System != conversation.System || len(chat.conversation.Messages) != 3 {
+		t.Errorf("chat provider got %+v, want %+v", chat.conversation, conversation)
+	}
+
+	text := &textProvider{}
+	result, err = Send(context.Background(), text, conversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Text != "generated" {
+		t.Errorf("text provider answered %q", result.Text)
+	}
+	if text.text != conversation.Text() {
+		t.Errorf("text provider got %q, want %q", text.text, conversation.Text())
+	}
+}
+
+func TestChatMessages(t *testing.T) {
+	conversation := prompt.Prompt{
+		System:   "sys",
+		Messages: []prompt.Message{{Role: prompt.RoleUser, Content: "hi"}},
+	}
+
+	got := chatMessages(conversation, "content", true)
+	if len(got) != 2 || got[0]["role"] != "system" || got[0]["content"] != "sys" || got[1]["role"] != "user" || got[1]["content"] != "hi" {
+		t.Errorf("with system: %v", got)
+	}
+
+	got = chatMessages(conversation, "text", false)
+	if len(got) != 1 || got[0]["role"] != "user" || got[0]["text"] != "hi" {
+		t.Errorf("without system: %v", got)
+	}
+}
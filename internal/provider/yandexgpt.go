@@ -8,8 +8,10 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
 )
 
 type YandexGPTProvider struct {
@@ -17,6 +19,7 @@ type YandexGPTProvider struct {
 	iamToken string
 	folderID string
 	model    string
+	client   *http.Client
 }
 
 func NewYandexGPTProvider(cfg *config.Config) (*YandexGPTProvider, error) {
@@ -46,6 +49,7 @@ func NewYandexGPTProvider(cfg *config.Config) (*YandexGPTProvider, error) {
 		iamToken: iamToken,
 		folderID: folderID,
 		model:    model,
+		client:   newHTTPClient(cfg, "yandexgpt"),
 	}, nil
 }
 
@@ -53,6 +57,10 @@ func (p *YandexGPTProvider) Name() string {
 	return "yandexgpt"
 }
 
+func (p *YandexGPTProvider) Model() string {
+	return p.model
+}
+
 func (p *YandexGPTProvider) Validate() error {
 	if p.apiKey == "" && p.iamToken == "" {
 		return fmt.Errorf("no API key or IAM token configured")
@@ -63,7 +71,11 @@ func (p *YandexGPTProvider) Validate() error {
 	return nil
 }
 
-func (p *YandexGPTProvider) Generate(ctx context.Context, prompt string) (string, error) {
+func (p *YandexGPTProvider) Generate(ctx context.Context, text string) (*Result, error) {
+	return p.Chat(ctx, prompt.User(text))
+}
+
+func (p *YandexGPTProvider) Chat(ctx context.Context, conversation prompt.Prompt) (*Result, error) {
 	modelURI := fmt.Sprintf("gpt://%s/%s/latest", p.folderID, p.model)
 
 	reqBody := map[string]any{
@@ -73,21 +85,19 @@ func (p *YandexGPTProvider) Generate(ctx context.Context, prompt string) (string
 			"temperature": 0.3,
 			"maxTokens":   "1024",
 		},
-		"messages": []map[string]string{
-			{"role": "user", "text": prompt},
-		},
+		"messages": chatMessages(conversation, "text", true),
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST",
 		"https://llm.api.cloud.yandex.net/foundationModels/v1/completion",
 		bytes.NewReader(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -99,19 +109,19 @@ func (p *YandexGPTProvider) Generate(ctx context.Context, prompt string) (string
 		req.Header.Set("Authorization", "Bearer "+p.iamToken)
 	}
 
-	resp, err := httpClient.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
@@ -122,16 +132,29 @@ func (p *YandexGPTProvider) Generate(ctx context.Context, prompt string) (string
 					Text string `json:"text"`
 				} `json:"message"`
 			} `json:"alternatives"`
+			Usage struct {
+				InputTextTokens  string `json:"inputTextTokens"`
+				CompletionTokens string `json:"completionTokens"`
+			} `json:"usage"`
 		} `json:"result"`
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if len(result.Result.Alternatives) == 0 {
-		return "", fmt.Errorf("empty response from API")
+		return nil, fmt.Errorf("empty response from API")
 	}
 
-	return result.Result.Alternatives[0].Message.Text, nil
+	promptTokens, _ := strconv.Atoi(result.Result.Usage.InputTextTokens)
+	completionTokens, _ := strconv.Atoi(result.Result.Usage.CompletionTokens)
+
+	return &Result{
+		Text: result.Result.Alternatives[0].Message.Text,
+		Usage: Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+		},
+	}, nil
 }
@@ -0,0 +1,47 @@
+// Package rpc holds the JSON-RPC 2.0 types shared by the MCP and LSP
+// servers.
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// JSON-RPC error codes
+const (
+	CodeParseError     = -32700
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Message is any JSON-RPC 2.0 message: a request has a method and an
+// id, a notification only a method, a response only an id
+type Message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *Error           `json:"error,omitempty"`
+}
+
+// Error is the error of a response
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NullID is the id of a response to a message that could not be read
+func NullID() *json.RawMessage {
+	null := json.RawMessage("null")
+	return &null
+}
+
+// Generator writes a commit message for the staged changes of the working
+// tree in dir. Regenerate skips cached responses.
+type Generator func(ctx context.Context, dir string, regenerate bool) (string, error)
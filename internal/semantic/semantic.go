@@ -0,0 +1,382 @@
+package semantic
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/josinSbazin/AutoCommit/internal/git"
+)
+
+// Change kinds
+const (
+	Added     = "added"
+	Removed   = "removed"
+	Signature = "signature"
+	Interface = "interface"
+	Field     = "field"
+	Kind      = "kind"
+)
+
+// Change is a change to the exported API of a Go package
+type Change struct {
+	Kind     string
+	Package  string
+	Symbol   string
+	Old      string
+	New      string
+	Detail   string
+	Breaking bool
+}
+
+// String describes the change in one line for the prompt
+func (c Change) String() string {
+	name := c.Package + "." + c.Symbol
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("added %s", c.New)
+	case Removed:
+		return fmt.Sprintf("removed %s", c.Old)
+	case Signature:
+		return fmt.Sprintf("changed signature of %s: %s -> %s", name, c.Old, c.New)
+	case Interface:
+		return fmt.Sprintf("interface %s: %s", name, c.Detail)
+	case Field:
+		return fmt.Sprintf("struct %s: %s", name, c.Detail)
+	default:
+		return fmt.Sprintf("%s changed from %s to %s", name, c.Old, c.New)
+	}
+}
+
+// decl is an exported declaration and its rendered signature
+type decl struct {
+	kind    string // func, type
+	sig     string
+	methods map[string]string // interface methods
+	fields  map[string]string // exported struct fields
+}
+
+// pkgDecls are the declarations of the changed files of one package
+// directory, before and after
+type pkgDecls struct {
+	oldPkg, newPkg     string
+	oldDecls, newDecls map[string]decl
+}
+
+// AnalyzeGo compares exported declarations of staged .go files against
+// HEAD. Declarations are gathered per package directory first, so moving
+// one between files of a package is not a change.
+func AnalyzeGo(diff *git.DiffResult) []Change {
+	dirs := make(map[string]*pkgDecls)
+	get := func(dir string) *pkgDecls {
+		p, ok := dirs[dir]
+		if !ok {
+			p = &pkgDecls{oldDecls: make(map[string]decl), newDecls: make(map[string]decl)}
+			dirs[dir] = p
+		}
+		return p
+	}
+
+	for _, f := range diff.Files {
+		if path.Ext(f.Path) != ".go" || strings.HasSuffix(f.Path, "_test.go") || f.IsBinary {
+			continue
+		}
+
+		oldData, newData := diff.Images(f)
+		// A copy leaves its source in place, so it only adds
+		if f.Status == "copied" {
+			oldData = nil
+		}
+		oldPath := f.Path
+		if f.OldPath != "" {
+			oldPath = f.OldPath
+		}
+
+		if pkg, decls := parseDecls(oldData); pkg != "" {
+			p := get(path.Dir(oldPath))
+			p.oldPkg = pkg
+			for name, d := range decls {
+				p.oldDecls[name] = d
+			}
+		}
+		if pkg, decls := parseDecls(newData); pkg != "" {
+			p := get(path.Dir(f.Path))
+			p.newPkg = pkg
+			for name, d := range decls {
+				p.newDecls[name] = d
+			}
+		}
+	}
+
+	names := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		names = append(names, dir)
+	}
+	sort.Strings(names)
+
+	var changes []Change
+	for _, dir := range names {
+		p := dirs[dir]
+		pkg := p.newPkg
+		if pkg == "" {
+			pkg = p.oldPkg
+		}
+		public := pkg != "main" && !isInternal(dir+"/")
+		changes = append(changes, compare(pkg, public, p.oldDecls, p.newDecls)...)
+	}
+
+	return changes
+}
+
+// HasBreaking reports whether any change may break importers
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+func isInternal(p string) bool {
+	return strings.HasPrefix(p, "internal/") || strings.Contains(p, "/internal/")
+}
+
+func compare(pkg string, public bool, oldDecls, newDecls map[string]decl) []Change {
+	var changes []Change
+
+	names := make([]string, 0, len(oldDecls)+len(newDecls))
+	for name := range oldDecls {
+		names = append(names, name)
+	}
+	for name := range newDecls {
+		if _, ok := oldDecls[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		o, inOld := oldDecls[name]
+		n, inNew := newDecls[name]
+		c := Change{Package: pkg, Symbol: name}
+
+		switch {
+		case !inOld:
+			c.Kind, c.New = Added, n.kind+" "+pkg+"."+name+n.sig
+		case !inNew:
+			c.Kind, c.Old, c.Breaking = Removed, o.kind+" "+pkg+"."+name+o.sig, public
+		case o.kind == "func" && n.kind == "func":
+			if o.sig == n.sig {
+				continue
+			}
+			c.Kind, c.Old, c.New, c.Breaking = Signature, o.sig, n.sig, public
+		default:
+			// Type parameters are part of sig, so changing them lands here
+			if o.kind != n.kind || o.sig != n.sig {
+				changes = append(changes, Change{
+					Kind: Kind, Package: pkg, Symbol: name, Old: o.kind + o.sig, New: n.kind + n.sig, Breaking: public,
+				})
+			}
+			changes = append(changes, memberChanges(pkg, name, public, o, n)...)
+			continue
+		}
+		changes = append(changes, c)
+	}
+
+	return changes
+}
+
+// memberChanges compares the methods of interfaces and the fields of
+// structs. Only added fields are harmless to importers.
+func memberChanges(pkg, name string, public bool, o, n decl) []Change {
+	var changes []Change
+	if o.methods != nil && n.methods != nil {
+		for _, detail := range methodChanges(o.methods, n.methods) {
+			changes = append(changes, Change{
+				Kind: Interface, Package: pkg, Symbol: name, Detail: detail, Breaking: public,
+			})
+		}
+	}
+	if o.fields != nil && n.fields != nil {
+		for _, detail := range fieldChanges(o.fields, n.fields) {
+			changes = append(changes, Change{
+				Kind: Field, Package: pkg, Symbol: name, Detail: detail,
+				Breaking: public && !strings.HasPrefix(detail, "added "),
+			})
+		}
+	}
+	return changes
+}
+
+func fieldChanges(oldFields, newFields map[string]string) []string {
+	var details []string
+	for name, typ := range newFields {
+		old, ok := oldFields[name]
+		switch {
+		case !ok:
+			details = append(details, "added field "+name+" "+typ)
+		case old != typ:
+			details = append(details, fmt.Sprintf("changed field %s %s -> %s", name, old, typ))
+		}
+	}
+	for name, typ := range oldFields {
+		if _, ok := newFields[name]; !ok {
+			details = append(details, "removed field "+name+" "+typ)
+		}
+	}
+	sort.Strings(details)
+	return details
+}
+
+func methodChanges(oldMethods, newMethods map[string]string) []string {
+	var details []string
+	for name, sig := range newMethods {
+		old, ok := oldMethods[name]
+		switch {
+		case !ok:
+			details = append(details, "added method "+name+sig)
+		case old != sig:
+			details = append(details, fmt.Sprintf("changed method %s%s -> %s%s", name, old, name, sig))
+		}
+	}
+	for name, sig := range oldMethods {
+		if _, ok := newMethods[name]; !ok {
+			details = append(details, "removed method "+name+sig)
+		}
+	}
+	sort.Strings(details)
+	return details
+}
+
+// parseDecls collects exported funcs, methods and types of a Go source
+func parseDecls(data []byte) (string, map[string]decl) {
+	decls := make(map[string]decl)
+	if len(data) == 0 {
+		return "", decls
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", data, parser.SkipObjectResolution)
+	if err != nil {
+		return "", decls
+	}
+
+	for _, d := range file.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverName(d.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				name = recv + "." + name
+			}
+			decls[name] = decl{kind: "func", sig: strings.TrimPrefix(render(fset, d.Type), "func")}
+
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if !ts.Name.IsExported() {
+					continue
+				}
+				decls[ts.Name.Name] = typeDecl(fset, ts)
+			}
+		}
+	}
+
+	return file.Name.Name, decls
+}
+
+func typeDecl(fset *token.FileSet, ts *ast.TypeSpec) decl {
+	params := typeParams(fset, ts.TypeParams)
+	switch t := ts.Type.(type) {
+	case *ast.InterfaceType:
+		methods := make(map[string]string)
+		for _, m := range t.Methods.List {
+			ft, ok := m.Type.(*ast.FuncType)
+			if !ok {
+				// embedded interface or type constraint
+				methods[render(fset, m.Type)] = ""
+				continue
+			}
+			for _, n := range m.Names {
+				methods[n.Name] = strings.TrimPrefix(render(fset, ft), "func")
+			}
+		}
+		return decl{kind: "type", sig: params + " interface", methods: methods}
+	case *ast.StructType:
+		fields := make(map[string]string)
+		for _, f := range t.Fields.List {
+			typ := render(fset, f.Type)
+			if len(f.Names) == 0 {
+				// embedded field, named after its type
+				if name := receiverName(f.Type); ast.IsExported(name) {
+					fields[name] = typ
+				}
+				continue
+			}
+			for _, n := range f.Names {
+				if n.IsExported() {
+					fields[n.Name] = typ
+				}
+			}
+		}
+		return decl{kind: "type", sig: params + " struct", fields: fields}
+	default:
+		return decl{kind: "type", sig: params + " " + render(fset, ts.Type)}
+	}
+}
+
+// typeParams renders a type parameter list as [K comparable, V any]
+func typeParams(fset *token.FileSet, list *ast.FieldList) string {
+	if list == nil || len(list.List) == 0 {
+		return ""
+	}
+	var params []string
+	for _, f := range list.List {
+		var names []string
+		for _, n := range f.Names {
+			names = append(names, n.Name)
+		}
+		params = append(params, strings.Join(names, ", ")+" "+render(fset, f.Type))
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+func render(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
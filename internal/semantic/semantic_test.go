@@ -0,0 +1,135 @@
+package semantic
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/josinSbazin/AutoCommit/internal/git"
+)
+
+// stage commits before in a temp repository, stages after on top (an
+// empty content deletes the file) and returns the staged diff
+func stage(t *testing.T, before, after map[string]string) *git.DiffResult {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			full := filepath.Join(dir, name)
+			if content == "" {
+				os.Remove(full)
+				continue
+			}
+			os.MkdirAll(filepath.Dir(full), 0755)
+			if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		run("add", "-A")
+	}
+
+	run("init", "-q")
+	write(before)
+	run("-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init")
+	write(after)
+
+	diff, err := (&git.ExecRepository{Dir: dir}).StagedDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return diff
+}
+
+func describe(changes []Change) string {
+	var lines []string
+	for _, c := range changes {
+		line := c.String()
+		if c.Breaking {
+			line += " (breaking)"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestMoveBetweenFiles(t *testing.T) {
+	diff := stage(t, map[string]string{
+		"api/a.go": "package api\n\nfunc Open(name string) error { return nil }\n\ntype Options struct{ Name string }\n",
+		"api/b.go": "package api\n\nfunc Close() {}\n",
+	}, map[string]string{
+		"api/a.go": "package api\n\nfunc Close() {}\n",
+		"api/b.go": "package api\n\nfunc Open(name string) error { return nil }\n\ntype Options struct{ Name string }\n",
+	})
+	if changes := AnalyzeGo(diff); len(changes) != 0 {
+		t.Errorf("moving declarations reported:\n%s", describe(changes))
+	}
+}
+
+func TestMoveToNewFile(t *testing.T) {
+	diff := stage(t, map[string]string{
+		"api/a.go": "package api\n\nfunc Open() {}\n\nfunc Close() {}\n",
+	}, map[string]string{
+		"api/a.go":     "package api\n\nfunc Open() {}\n",
+		"api/close.go": "package api\n\nfunc Close() {}\n",
+	})
+	if changes := AnalyzeGo(diff); len(changes) != 0 {
+		t.Errorf("splitting a file reported:\n%s", describe(changes))
+	}
+}
+
+func TestStructFields(t *testing.T) {
+	diff := stage(t, map[string]string{
+		"api/a.go": "package api\n\nimport \"io\"\n\ntype Options struct {\n\tName string\n\tSize int\n\tio.Reader\n\tprivate bool\n}\n",
+	}, map[string]string{
+		"api/a.go": "package api\n\ntype Options struct {\n\tName string\n\tSize int64\n\tLabel string\n}\n",
+	})
+	want := `struct api.Options: added field Label string
+struct api.Options: changed field Size int -> int64 (breaking)
+struct api.Options: removed field Reader io.Reader (breaking)`
+	if got := describe(AnalyzeGo(diff)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTypeParameters(t *testing.T) {
+	diff := stage(t, map[string]string{
+		"api/a.go": "package api\n\ntype List[T any] struct{ Items []T }\n\nfunc Map[T any](l List[T]) {}\n",
+	}, map[string]string{
+		"api/a.go": "package api\n\ntype List[T comparable] struct{ Items []T }\n\nfunc Map[T comparable](l List[T]) {}\n",
+	})
+	want := `api.List changed from type[T any] struct to type[T comparable] struct (breaking)
+changed signature of api.Map: [T any](l List[T]) -> [T comparable](l List[T]) (breaking)`
+	if got := describe(AnalyzeGo(diff)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestInternalAndRemoved(t *testing.T) {
+	diff := stage(t, map[string]string{
+		"internal/x/x.go": "package x\n\nfunc Gone() {}\n",
+		"api/a.go":        "package api\n\nfunc Gone() {}\n",
+	}, map[string]string{
+		"internal/x/x.go": "",
+		"api/a.go":        "package api\n",
+	})
+	want := `removed func api.Gone() (breaking)
+removed func x.Gone()`
+	if got := describe(AnalyzeGo(diff)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+)
+
+// dialTimeout keeps the CLI fast when no daemon is running
+const dialTimeout = 200 * time.Millisecond
+
+// ErrUnavailable means the daemon could not be reached, so the caller
+// should do the work itself
+var ErrUnavailable = errors.New("autocommit daemon unavailable")
+
+// Client talks to a running daemon
+type Client struct {
+	address string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a client for the daemon at address, authenticated
+// with the token the daemon wrote. TCP addresses must be loopback, as for
+// the daemon itself.
+func NewClient(address string) (*Client, error) {
+	if err := checkLoopback(address); err != nil {
+		return nil, fmt.Errorf("refusing to connect to %s: %w", address, err)
+	}
+	token, err := readToken(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read token: %v", ErrUnavailable, err)
+	}
+
+	netw := network(address)
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, netw, address)
+		},
+	}
+	return &Client{address: address, token: token, http: &http.Client{Transport: transport}}, nil
+}
+
+// Connect returns a client if a daemon is running for cfg and the config
+// allows using it, otherwise nil
+func Connect(cfg *config.Config) *Client {
+	if !cfg.Server.Enabled {
+		return nil
+	}
+	address := Address(cfg)
+	if address == "" {
+		return nil
+	}
+
+	c, err := NewClient(address)
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*dialTimeout)
+	defer cancel()
+	if err := c.Health(ctx); err != nil {
+		return nil
+	}
+	return c
+}
+
+// Address returns the address the client connects to
+func (c *Client) Address() string {
+	return c.address
+}
+
+// Health checks that the daemon answers
+func (c *Client) Health(ctx context.Context) error {
+	req, err := c.request(ctx, "GET", "/v1/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+	return nil
+}
+
+// Generate asks the daemon for a commit message
+func (c *Client) Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	var resp GenerateResponse
+	return &resp, c.post(ctx, "/v1/generate", req, &resp)
+}
+
+// Lint asks the daemon to check a commit message
+func (c *Client) Lint(ctx context.Context, req LintRequest) (*LintResponse, error) {
+	var resp LintResponse
+	return &resp, c.post(ctx, "/v1/lint", req, &resp)
+}
+
+// Validate asks the daemon to check the configured provider
+func (c *Client) Validate(ctx context.Context, req ValidateRequest) (*ValidateResponse, error) {
+	var resp ValidateResponse
+	return &resp, c.post(ctx, "/v1/validate", req, &resp)
+}
+
+// request addresses the daemon as localhost, which it requires of every
+// Host, and adds the token
+func (c *Client) request(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(TokenHeader, c.token)
+	return req, nil
+}
+
+func (c *Client) post(ctx context.Context, path string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := c.request(ctx, "POST", path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if json.Unmarshal(respBody, &e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return fmt.Errorf("daemon error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
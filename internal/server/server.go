@@ -0,0 +1,211 @@
+// Package server is the local API of 'autocommit serve': a long-lived
+// process that keeps providers, with their auth tokens and connections,
+// between requests from the CLI, the git hook and editors
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/message"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
+	"github.com/josinSbazin/AutoCommit/internal/provider"
+)
+
+const socketName = "autocommit.sock"
+
+// GenerateRequest asks for a message for the staged changes in Dir
+type GenerateRequest struct {
+	Dir      string `json:"dir"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// Prompt is used as is when set; otherwise it is built from Dir
+	Prompt  *prompt.Prompt `json:"prompt,omitempty"`
+	NoCache bool           `json:"no_cache,omitempty"`
+}
+
+// GenerateResponse is a generated message with its accounting metadata
+type GenerateResponse struct {
+	Message   string          `json:"message"`
+	Provider  string          `json:"provider"`
+	Model     string          `json:"model"`
+	Usage     provider.Usage  `json:"usage"`
+	Cost      float64         `json:"cost_usd"`
+	Unpriced  bool            `json:"unpriced,omitempty"`
+	LatencyMs int64           `json:"latency_ms"`
+	Cached    bool            `json:"cached"`
+	Issues    []message.Issue `json:"issues"`
+}
+
+// LintRequest asks to check a message against the rules configured for Dir
+type LintRequest struct {
+	Dir     string `json:"dir"`
+	Message string `json:"message"`
+}
+
+// LintResponse lists the problems found; Valid is false if any is an error
+type LintResponse struct {
+	Valid  bool            `json:"valid"`
+	Issues []message.Issue `json:"issues"`
+}
+
+// ValidateRequest asks whether the provider configured for Dir works
+type ValidateRequest struct {
+	Dir      string `json:"dir"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// ValidateResponse reports the provider that would be used and its state
+type ValidateResponse struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Valid    bool   `json:"valid"`
+	Error    string `json:"error,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Backend does the work behind the API
+type Backend interface {
+	Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error)
+	Lint(ctx context.Context, req LintRequest) (*LintResponse, error)
+	Validate(ctx context.Context, req ValidateRequest) (*ValidateResponse, error)
+}
+
+// Address returns where the daemon listens for cfg
+func Address(cfg *config.Config) string {
+	if cfg.Server.Address != "" {
+		return cfg.Server.Address
+	}
+	dir := config.GetGlobalConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, socketName)
+}
+
+// network tells a host:port address from a socket path
+func network(address string) string {
+	if strings.ContainsAny(address, `/\`) {
+		return "unix"
+	}
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return "tcp"
+	}
+	return "unix"
+}
+
+// checkLoopback rejects TCP addresses that leave the machine: the API
+// carries diffs and runs providers with the user's keys
+func checkLoopback(address string) error {
+	if network(address) != "tcp" {
+		return nil
+	}
+	host, _, _ := net.SplitHostPort(address)
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("use a loopback address")
+	}
+	return nil
+}
+
+// Listen opens the daemon's socket. A socket left behind by a daemon that
+// is no longer running is replaced; a live one is an error.
+func Listen(address string) (net.Listener, error) {
+	if address == "" {
+		return nil, fmt.Errorf("failed to determine config directory")
+	}
+
+	if network(address) == "tcp" {
+		if err := checkLoopback(address); err != nil {
+			return nil, fmt.Errorf("refusing to listen on %s: %w", address, err)
+		}
+		return net.Listen("tcp", address)
+	}
+
+	if _, err := os.Stat(address); err == nil {
+		if conn, err := net.DialTimeout("unix", address, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("autocommit is already serving on %s", address)
+		}
+		os.Remove(address)
+	}
+	if err := os.MkdirAll(filepath.Dir(address), 0700); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+	// The socket gives access to the user's API keys
+	if err := os.Chmod(address, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve answers API requests carrying token on l until ctx is done
+func Serve(ctx context.Context, l net.Listener, b Backend, token string) error {
+	srv := &http.Server{Handler: Handler(b, token)}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Handler routes the API to b for requests that pass guard
+func Handler(b Backend, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("POST /v1/generate", handle(b.Generate))
+	mux.HandleFunc("POST /v1/lint", handle(b.Lint))
+	mux.HandleFunc("POST /v1/validate", handle(b.Validate))
+	return guard(token, mux)
+}
+
+// handle decodes a request, runs fn and encodes its result or error
+func handle[Req, Resp any](fn func(context.Context, Req) (*Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request: " + err.Error()})
+			return
+		}
+
+		resp, err := fn(r.Context(), req)
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+)
+
+// TokenHeader carries the daemon's token on every request
+const TokenHeader = "X-Autocommit-Token"
+
+// TokenPath returns the file holding the token of the daemon at address
+func TokenPath(address string) string {
+	if network(address) == "unix" {
+		return address + ".token"
+	}
+	dir := config.GetGlobalConfigDir()
+	if dir == "" {
+		return ""
+	}
+	name := strings.NewReplacer(":", "-", "[", "", "]", "").Replace(address)
+	return filepath.Join(dir, "autocommit-"+name+".token")
+}
+
+// WriteToken creates a random token for the daemon at address in a file
+// only the user can read, and returns it
+func WriteToken(address string) (string, error) {
+	path := TokenPath(address)
+	if path == "" {
+		return "", fmt.Errorf("failed to determine config directory")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(secret)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	// A fresh file, so one created by someone else with looser
+	// permissions is never reused
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to write token: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(token + "\n"); err != nil {
+		return "", fmt.Errorf("failed to write token: %w", err)
+	}
+	return token, nil
+}
+
+// readToken returns the token of the daemon at address
+func readToken(address string) (string, error) {
+	path := TokenPath(address)
+	if path == "" {
+		return "", fmt.Errorf("failed to determine config directory")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// guard admits only requests from local clients. The token keeps out
+// other users and processes that can reach a TCP port, the Host check
+// keeps out web pages using DNS rebinding, and requiring JSON keeps out
+// the plain form posts browsers send cross-origin without asking.
+func guard(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host) {
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: "host not allowed: " + r.Host})
+			return
+		}
+		given := r.Header.Get(TokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "missing or invalid " + TokenHeader})
+			return
+		}
+		if r.Method == http.MethodPost {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				writeJSON(w, http.StatusUnsupportedMediaType, errorResponse{Error: "Content-Type must be application/json"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isLoopbackHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type lintBackend struct{ Backend }
+
+func (lintBackend) Lint(ctx context.Context, req LintRequest) (*LintResponse, error) {
+	return &LintResponse{Valid: true}, nil
+}
+
+func TestGuard(t *testing.T) {
+	h := Handler(lintBackend{}, "secret")
+
+	tests := []struct {
+		name, host, token, contentType string
+		want                           int
+	}{
+		{"ok", "localhost", "secret", "application/json", http.StatusOK},
+		{"loopback port", "127.0.0.1:7070", "secret", "application/json; charset=utf-8", http.StatusOK},
+		{"ipv6", "[::1]:7070", "secret", "application/json", http.StatusOK},
+		{"rebinding", "evil.example.com:7070", "secret", "application/json", http.StatusForbidden},
+		{"no token", "localhost", "", "application/json", http.StatusUnauthorized},
+		{"wrong token", "localhost", "guess", "application/json", http.StatusUnauthorized},
+		{"form post", "localhost", "secret", "text/plain", http.StatusUnsupportedMediaType},
+		{"no content type", "localhost", "secret", "", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/v1/lint", strings.NewReader(`{"dir": "/tmp", "message": "x"}`))
+		req.Host = tt.host
+		if tt.token != "" {
+			req.Header.Set(TokenHeader, tt.token)
+		}
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestGuardWithoutToken(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/health", nil)
+	req.Host = "localhost"
+	rec := httptest.NewRecorder()
+	Handler(lintBackend{}, "").ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestCheckLoopback(t *testing.T) {
+	for address, ok := range map[string]bool{
+		"/tmp/autocommit.sock": true,
+		"127.0.0.1:7070":       true,
+		"localhost:7070":       true,
+		"[::1]:7070":           true,
+		"0.0.0.0:7070":         false,
+		"example.com:80":       false,
+	} {
+		if err := checkLoopback(address); (err == nil) != ok {
+			t.Errorf("checkLoopback(%q) = %v", address, err)
+		}
+	}
+}
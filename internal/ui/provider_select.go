@@ -52,6 +52,12 @@ var providers = []providerInfo{
 		description: "Groq, Together AI, LM Studio, etc.",
 		envVars:     []string{"AUTOCOMMIT_API_KEY"},
 	},
+	{
+		name:        "heuristic",
+		displayName: "Offline (rule-based)",
+		description: "No LLM, no network - drafts from diff analysis",
+		envVars:     []string{},
+	},
 }
 
 // SelectProvider shows provider selection menu
@@ -65,7 +71,7 @@ func SelectProvider() string {
 	}
 
 	fmt.Println()
-	fmt.Print("Enter number (1-6): ")
+	fmt.Printf("Enter number (1-%d): ", len(providers))
 
 	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
@@ -131,6 +137,11 @@ func GetProviderCredentials(providerName string) (string, map[string]string) {
 		}
 		return "", extra
 
+	case "heuristic":
+		fmt.Println()
+		fmt.Println("Messages are drafted locally from the staged diff. No credentials needed.")
+		return "", extra
+
 	case "openai-compatible":
 		fmt.Println()
 		fmt.Println("Enter endpoint URL (e.g., https://api.groq.com/openai/v1):")
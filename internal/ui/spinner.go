@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"os"
 	"sync"
 	"time"
 )
@@ -15,17 +16,22 @@ type Spinner struct {
 	mu      sync.Mutex
 }
 
-// NewSpinner creates a new spinner
+// NewSpinner creates a new spinner. The spinner stays silent when
+// stdout is not a terminal.
 func NewSpinner(message string) *Spinner {
 	return &Spinner{
 		message: message,
 		frames:  []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
 		stop:    make(chan struct{}),
+		stopped: !isTerminal(os.Stdout),
 	}
 }
 
 // Start begins the spinner animation
 func (s *Spinner) Start() {
+	if s.stopped {
+		return
+	}
 	go func() {
 		i := 0
 		for {
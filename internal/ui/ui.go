@@ -41,30 +41,51 @@ func PrintCommitMessage(message string) {
 	fmt.Println()
 }
 
-// AskAction prompts user for action
+// AskAction prompts user for action. Unknown input is rejected and the
+// prompt is repeated; a closed stdin is treated as quit.
 func AskAction() Action {
-	fmt.Print(colorBold + "[Enter]" + colorReset + " Accept  ")
-	fmt.Print(colorBold + "[e]" + colorReset + " Edit  ")
-	fmt.Print(colorBold + "[r]" + colorReset + " Regenerate  ")
-	fmt.Print(colorBold + "[q]" + colorReset + " Quit")
-	fmt.Print("\n> ")
-
 	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(strings.ToLower(input))
 
-	switch input {
-	case "", "y", "yes":
-		return ActionAccept
-	case "e", "edit":
-		return ActionEdit
-	case "r", "regenerate", "retry":
-		return ActionRegenerate
-	case "q", "quit", "exit", "n", "no":
-		return ActionQuit
-	default:
-		return ActionAccept
+	for {
+		fmt.Print(colorBold + "[Enter]" + colorReset + " Accept  ")
+		fmt.Print(colorBold + "[e]" + colorReset + " Edit  ")
+		fmt.Print(colorBold + "[r]" + colorReset + " Regenerate  ")
+		fmt.Print(colorBold + "[q]" + colorReset + " Quit")
+		fmt.Print("\n> ")
+
+		input, err := reader.ReadString('\n')
+		if err != nil && input == "" {
+			fmt.Println()
+			return ActionQuit
+		}
+		input = strings.TrimSpace(strings.ToLower(input))
+
+		switch input {
+		case "", "y", "yes":
+			return ActionAccept
+		case "e", "edit":
+			return ActionEdit
+		case "r", "regenerate", "retry":
+			return ActionRegenerate
+		case "q", "quit", "exit", "n", "no":
+			return ActionQuit
+		default:
+			PrintWarning(fmt.Sprintf("Unknown option %q", input))
+		}
+	}
+}
+
+// IsInteractive reports whether stdin and stdout are attached to a terminal
+func IsInteractive() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
 	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
 // EditInEditor opens the message in the default editor
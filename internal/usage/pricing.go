@@ -0,0 +1,60 @@
+package usage
+
+import (
+	"strings"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/provider"
+)
+
+// defaultPricing holds list prices in USD per million tokens.
+// Keys are matched as model name prefixes, longest first.
+var defaultPricing = map[string]config.ModelPrice{
+	"claude-opus-4":     {Prompt: 15, Completion: 75},
+	"claude-sonnet-4":   {Prompt: 3, Completion: 15},
+	"claude-3-7-sonnet": {Prompt: 3, Completion: 15},
+	"claude-3-5-sonnet": {Prompt: 3, Completion: 15},
+	"claude-3-5-haiku":  {Prompt: 0.8, Completion: 4},
+	"gpt-4o-mini":       {Prompt: 0.15, Completion: 0.6},
+	"gpt-4o":            {Prompt: 2.5, Completion: 10},
+	"gpt-4.1-mini":      {Prompt: 0.4, Completion: 1.6},
+	"gpt-4.1":           {Prompt: 2, Completion: 8},
+}
+
+// LookupPrice finds the price for a model, preferring the configured table.
+// Local providers are always free. Returns false if the model has no known price.
+func LookupPrice(cfg *config.Config, providerName, model string) (config.ModelPrice, bool) {
+	if providerName == "ollama" || providerName == "heuristic" {
+		return config.ModelPrice{}, true
+	}
+	if p, ok := matchPrice(cfg.Usage.Pricing, model); ok {
+		return p, true
+	}
+	return matchPrice(defaultPricing, model)
+}
+
+func matchPrice(table map[string]config.ModelPrice, model string) (config.ModelPrice, bool) {
+	if p, ok := table[model]; ok {
+		return p, true
+	}
+
+	best := ""
+	for key := range table {
+		if strings.HasPrefix(model, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return config.ModelPrice{}, false
+	}
+	return table[best], true
+}
+
+// Cost estimates the cost of a request in USD
+func Cost(cfg *config.Config, providerName, model string, u provider.Usage) float64 {
+	price, ok := LookupPrice(cfg, providerName, model)
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*price.Prompt + float64(u.CompletionTokens)*price.Completion) / 1e6
+}
@@ -0,0 +1,99 @@
+package usage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// Total aggregates a group of records
+type Total struct {
+	Key              string
+	Label            string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	Unpriced         int
+}
+
+// Add counts rec in the total
+func (t *Total) Add(rec Record) {
+	t.Requests++
+	t.PromptTokens += rec.PromptTokens
+	t.CompletionTokens += rec.CompletionTokens
+	t.Cost += rec.Cost
+	if rec.Unpriced {
+		t.Unpriced++
+	}
+}
+
+// CostString formats the cost, marking requests to models without a known price
+func (t Total) CostString() string {
+	switch {
+	case t.Unpriced == 0:
+		return fmt.Sprintf("%.4f", t.Cost)
+	case t.Unpriced == t.Requests:
+		return "unknown"
+	default:
+		return fmt.Sprintf("%.4f + unknown", t.Cost)
+	}
+}
+
+// Summarize groups records by day, provider or repo
+func Summarize(records []Record, by string) []Total {
+	totals := map[string]*Total{}
+
+	for _, r := range records {
+		var key string
+		switch by {
+		case "provider":
+			key = r.Provider + "/" + r.Model
+		case "repo":
+			key = r.Repo
+			if r.Repo == "" {
+				key = "(none)"
+			}
+		default:
+			key = r.Time.Local().Format("2006-01-02")
+		}
+
+		t, ok := totals[key]
+		if !ok {
+			t = &Total{Key: key, Label: key}
+			totals[key] = t
+		}
+		t.Add(r)
+	}
+
+	result := make([]Total, 0, len(totals))
+	for _, t := range totals {
+		result = append(result, *t)
+	}
+	if by == "repo" {
+		labelRepos(result)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Label != result[j].Label {
+			return result[i].Label < result[j].Label
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// labelRepos shortens repo paths to their base name unless two repos
+// share it, in which case both keep the full path
+func labelRepos(totals []Total) {
+	bases := map[string]int{}
+	for _, t := range totals {
+		if t.Key != "(none)" {
+			bases[filepath.Base(t.Key)]++
+		}
+	}
+	for i, t := range totals {
+		if t.Key != "(none)" && bases[filepath.Base(t.Key)] == 1 {
+			totals[i].Label = filepath.Base(t.Key)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeRepos(t *testing.T) {
+	now := time.Now()
+	records := []Record{
+		{Time: now, Repo: "/work/a/api", PromptTokens: 10, Cost: 0.01},
+		{Time: now, Repo: "/work/b/api", PromptTokens: 20, Unpriced: true},
+		{Time: now, Repo: "/work/b/api", PromptTokens: 5, Cost: 0.02},
+		{Time: now, Repo: "/work/web", PromptTokens: 1, Unpriced: true},
+	}
+
+	got := Summarize(records, "repo")
+	want := []struct{ label, cost string }{
+		{"/work/a/api", "0.0100"},
+		{"/work/b/api", "0.0200 + unknown"},
+		{"web", "unknown"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d groups: %+v", len(got), got)
+	}
+	for i, w := range want {
+		if got[i].Label != w.label || got[i].CostString() != w.cost {
+			t.Errorf("group %d = %q %q, want %q %q", i, got[i].Label, got[i].CostString(), w.label, w.cost)
+		}
+	}
+}
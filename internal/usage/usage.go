@@ -0,0 +1,87 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+)
+
+// Record is a single entry in the usage log
+type Record struct {
+	Time             time.Time `json:"time"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	Repo             string    `json:"repo,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost_usd"`
+	Unpriced         bool      `json:"unpriced,omitempty"`
+}
+
+// LogPath returns the path of the usage log in the global config directory
+func LogPath() string {
+	dir := config.GetGlobalConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "usage.jsonl")
+}
+
+// Append adds a record to the usage log
+func Append(rec Record) error {
+	path := LogPath()
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Load reads all records newer than since from the usage log
+func Load(since time.Time) ([]Record, error) {
+	path := LogPath()
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec.Time.Before(since) {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
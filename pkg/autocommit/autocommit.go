@@ -0,0 +1,280 @@
+// Package autocommit generates commit messages from git changes for use
+// from other Go programs.
+//
+// The package follows semantic versioning: within a major version,
+// exported identifiers, including the fields of aliased types, are not
+// removed or changed incompatibly. Everything under internal/ may change
+// at any time.
+//
+// Unlike the autocommit command, Generate does not read config files,
+// cache responses, enforce budgets or log usage.
+package autocommit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/josinSbazin/AutoCommit/internal/config"
+	"github.com/josinSbazin/AutoCommit/internal/git"
+	"github.com/josinSbazin/AutoCommit/internal/message"
+	"github.com/josinSbazin/AutoCommit/internal/prompt"
+	"github.com/josinSbazin/AutoCommit/internal/provider"
+)
+
+type (
+	// Config holds all configuration options, as in .autocommit.yml
+	Config = config.Config
+	// ConventionalConfig holds the Conventional Commits rules
+	ConventionalConfig = config.ConventionalConfig
+	// ContextConfig selects what context goes into the prompt
+	ContextConfig = config.ContextConfig
+	// BehaviorConfig holds command line behavior settings
+	BehaviorConfig = config.BehaviorConfig
+	// UsageConfig holds usage logging and pricing settings
+	UsageConfig = config.UsageConfig
+	// ModelPrice is the price of a model in USD per million tokens
+	ModelPrice = config.ModelPrice
+	// BudgetConfig holds spending and rate limits
+	BudgetConfig = config.BudgetConfig
+	// CacheConfig holds response cache settings
+	CacheConfig = config.CacheConfig
+	// TimeoutConfig holds provider request timeouts
+	TimeoutConfig = config.TimeoutConfig
+	// ProviderTimeout overrides timeouts for a single provider
+	ProviderTimeout = config.ProviderTimeout
+	// ServerConfig holds background daemon settings
+	ServerConfig = config.ServerConfig
+
+	// Diff is a parsed set of changes
+	Diff = git.DiffResult
+	// DiffStats holds overall diff statistics
+	DiffStats = git.DiffStats
+	// FileChange represents changes to a single file
+	FileChange = git.FileChange
+	// Commit is a commit from the repository history
+	Commit = git.Commit
+	// Repository is the git repository a Diff was read from
+	Repository = git.Repository
+
+	// Provider is a backend that turns a prompt into a commit message
+	Provider = provider.Provider
+	// ChatProvider is a Provider that takes the system prompt and the
+	// conversation separately. Generate uses it when available.
+	ChatProvider = provider.ChatProvider
+	// ProviderFactory creates a provider from configuration
+	ProviderFactory = provider.Factory
+	// Completion is the outcome of a single provider request
+	Completion = provider.Result
+	// Usage holds token counts reported by a provider
+	Usage = provider.Usage
+
+	// Prompt is a system prompt and a conversation for a provider
+	Prompt = prompt.Prompt
+	// PromptMessage is one turn of a Prompt's conversation
+	PromptMessage = prompt.Message
+
+	// Message is a commit message split into subject, body and trailers
+	Message = message.Message
+	// Trailer is a "Key: value" line at the end of a commit message
+	Trailer = message.Trailer
+	// Issue is a single validation problem
+	Issue = message.Issue
+)
+
+// Values of Config.GitBackend
+const (
+	GitBackendExec = git.BackendExec
+	GitBackendGo   = git.BackendGo
+)
+
+// Issue severities
+const (
+	SeverityError   = message.SeverityError
+	SeverityWarning = message.SeverityWarning
+)
+
+// Roles of a PromptMessage
+const (
+	RoleUser      = prompt.RoleUser
+	RoleAssistant = prompt.RoleAssistant
+)
+
+// ErrNoChanges is returned by Generate when there is nothing to describe
+var ErrNoChanges = errors.New("no changes to describe")
+
+// Options configure a single Generate call
+type Options struct {
+	// RepoPath is the repository to read staged changes, history and
+	// branch from. Empty means the current directory.
+	RepoPath string
+
+	// Diff is a unified diff to describe instead of the staged changes,
+	// for example from git diff or git format-patch
+	Diff string
+
+	// Config defaults to DefaultConfig
+	Config *Config
+
+	// Provider overrides the provider selected by Config
+	Provider Provider
+}
+
+// Result is a generated commit message
+type Result struct {
+	Message  string
+	Provider string
+	Model    string
+	Usage    Usage
+	// Issues lists validation problems, which do not fail Generate
+	Issues []Issue
+}
+
+// Generate writes a commit message for the changes described by opts
+func Generate(ctx context.Context, opts Options) (Result, error) {
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+
+	diff, repo, err := readDiff(opts, cfg)
+	if err != nil {
+		return Result{}, err
+	}
+	if diff.IsEmpty() {
+		return Result{}, ErrNoChanges
+	}
+
+	var history []Commit
+	var branch string
+	if repo != nil {
+		history, _ = repo.History(cfg.Context.HistoryCount)
+		branch, _ = repo.Branch()
+	}
+
+	prov := opts.Provider
+	if prov == nil {
+		if prov, err = NewProvider(cfg); err != nil {
+			return Result{}, fmt.Errorf("failed to get provider: %w", err)
+		}
+	}
+
+	conversation, err := BuildConversation(cfg, diff, history, branch)
+	if err != nil {
+		return Result{}, err
+	}
+
+	completion, err := provider.Send(provider.WithDiff(ctx, diff), prov, conversation)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to generate message: %w", err)
+	}
+
+	return Result{
+		Message:  completion.Text,
+		Provider: prov.Name(),
+		Model:    prov.Model(),
+		Usage:    completion.Usage,
+		Issues:   Validate(cfg, completion.Text),
+	}, nil
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() *Config {
+	return config.Default()
+}
+
+// StagedDiff returns the changes Generate would describe for opts: the
+// parsed opts.Diff when set, otherwise the staged changes of opts.RepoPath
+// read with the git backend of opts.Config
+func StagedDiff(opts Options) (*Diff, error) {
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	diff, _, err := readDiff(opts, cfg)
+	return diff, err
+}
+
+// readDiff returns the diff for opts and the repository to read history
+// and branch from, nil when opts.Diff is set without a repository
+func readDiff(opts Options, cfg *Config) (*Diff, Repository, error) {
+	dir := opts.RepoPath
+	if dir == "" {
+		dir = "."
+	}
+
+	repo, err := git.Open(dir, cfg.GitBackend)
+	if err != nil {
+		if opts.Diff == "" || opts.RepoPath != "" {
+			return nil, nil, err
+		}
+		repo = nil
+	}
+
+	if opts.Diff != "" {
+		return ParseDiff(opts.Diff), repo, nil
+	}
+	diff, err := repo.StagedDiff()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get diff: %w", err)
+	}
+	return diff, repo, nil
+}
+
+// ParseDiff parses a unified diff in git's format
+func ParseDiff(raw string) *Diff {
+	return git.ParseDiff(raw)
+}
+
+// WithDiff returns a context carrying the diff being described. Generate
+// sets it; callers using a Provider directly should too, as providers that
+// describe the diff rather than the prompt, like heuristic, read it from
+// the context.
+func WithDiff(ctx context.Context, diff *Diff) context.Context {
+	return provider.WithDiff(ctx, diff)
+}
+
+// RegisterProvider makes a custom provider available as Config.Provider
+// name. Built-in provider names take precedence.
+func RegisterProvider(name string, factory ProviderFactory) {
+	provider.Register(name, factory)
+}
+
+// NewProvider returns the provider selected by cfg
+func NewProvider(cfg *Config) (Provider, error) {
+	return provider.Get(cfg)
+}
+
+// BuildPrompt returns the prompt for a diff as one text, as passed to
+// Provider.Generate. If cfg.PromptTemplate can't be read or rendered, the
+// built-in template is used instead.
+func BuildPrompt(cfg *Config, diff *Diff, history []Commit, branch string) string {
+	conversation, err := BuildConversation(cfg, diff, history, branch)
+	if err != nil {
+		builtin := *cfg
+		builtin.PromptTemplate = ""
+		conversation, _ = BuildConversation(&builtin, diff, history, branch)
+	}
+	return conversation.Text()
+}
+
+// BuildConversation returns the prompt for a diff with the system prompt
+// and the user message separate, as passed to ChatProvider.Chat
+func BuildConversation(cfg *Config, diff *Diff, history []Commit, branch string) (Prompt, error) {
+	return prompt.NewBuilder(cfg).Build(diff, history, branch)
+}
+
+// Validate checks a commit message against the configured rules
+func Validate(cfg *Config, msg string) []Issue {
+	return message.Validate(cfg, msg)
+}
+
+// HasErrors reports whether any issue is an error rather than a warning
+func HasErrors(issues []Issue) bool {
+	return message.HasErrors(issues)
+}
+
+// ParseMessage splits a commit message into subject, body and trailers
+func ParseMessage(raw string) Message {
+	return message.Parse(raw)
+}
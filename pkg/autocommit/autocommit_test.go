@@ -0,0 +1,69 @@
+package autocommit
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateWithGoBackend(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# demo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "README.md")
+
+	cfg := DefaultConfig()
+	cfg.Provider = "heuristic"
+	cfg.GitBackend = GitBackendGo
+	opts := Options{RepoPath: dir, Config: cfg}
+
+	diff, err := StagedDiff(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Files) != 1 || diff.Files[0].Path != "README.md" {
+		t.Fatalf("staged diff = %+v", diff.Files)
+	}
+
+	res, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Message, "README") {
+		t.Errorf("message = %q", res.Message)
+	}
+
+	// A diff in the options is described instead of the staged changes
+	opts.Diff = "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package a\n+package main\n"
+	if res, err = Generate(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Message, "main") {
+		t.Errorf("message for opts.Diff = %q", res.Message)
+	}
+
+	prov, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prov.Generate(context.Background(), ""); err == nil {
+		t.Error("heuristic provider without a diff on the context succeeded")
+	}
+}